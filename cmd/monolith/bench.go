@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/infrastructure/postgres"
+)
+
+func newBenchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Compare the read and write performance of every strategy",
+		Long: `Fetch invoices with every strategy repeatedly and compare them statistically,
+like GET /api/benchmark. With --write-rows, also insert, update and delete rows
+in every writable strategy, like POST /api/benchmark/write.`,
+		Args: cobra.NoArgs,
+		RunE: runBench,
+	}
+
+	flags := cmd.Flags()
+	flags.Int("limit", 10000, "invoices fetched per run")
+	flags.Int("iterations", 5, "measured runs per strategy")
+	flags.Int("warmup", 1, "unmeasured runs per strategy before the measured ones")
+	flags.String("order", string(application.OrderRandom), "strategy order within an iteration: sequential, alternating or random")
+	flags.Int("write-rows", 0, "rows inserted, updated and deleted per writable strategy, or 0 to skip writes")
+	return cmd
+}
+
+func runBench(cmd *cobra.Command, _ []string) error {
+	flags := cmd.Flags()
+	limit, _ := flags.GetInt("limit")
+	writeRows, _ := flags.GetInt("write-rows")
+	orderName, _ := flags.GetString("order")
+
+	opts := application.BenchmarkOptions{Query: invoices.NewQuery(limit)}
+	opts.Iterations, _ = flags.GetInt("iterations")
+	opts.Warmup, _ = flags.GetInt("warmup")
+
+	var err error
+	if opts.Order, err = application.ParseBenchmarkOrder(orderName); err != nil {
+		return err
+	}
+	if writeRows < 0 {
+		return fmt.Errorf("write rows must not be negative")
+	}
+
+	registry, err := registryFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	dbNetwork := postgres.NewNetworkCounter()
+	db, err := connect(dbNetwork)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service := application.NewInvoicesService(postgres.NewRepository(db, registry, dbNetwork))
+
+	result, err := service.RunBenchmark(cmd.Context(), opts)
+	if err != nil {
+		return fmt.Errorf("failed to run benchmark: %w", err)
+	}
+	printBenchmark(result)
+
+	if writeRows == 0 {
+		return nil
+	}
+	writes, err := service.RunWriteBenchmark(cmd.Context(), writeRows)
+	if err != nil {
+		return fmt.Errorf("failed to run write benchmark: %w", err)
+	}
+	printWriteBenchmark(writes)
+	return nil
+}
+
+// printBenchmark writes the mean of the main metrics per strategy and the
+// comparison of the two best strategies per metric to stdout
+func printBenchmark(result application.BenchmarkResult) {
+	opts := result.Options
+	fmt.Printf("Read benchmark: %d invoices, %d iterations after %d warmup, %s order\n\n",
+		opts.Query.Limit, opts.Iterations, opts.Warmup, opts.Order)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "STRATEGY\tROWS\tTOTAL MS\t95% CI\tP95 MS\tQUERY MS\tMEMORY BYTES\tDB BYTES RECEIVED\t")
+	for _, s := range result.Strategies {
+		total := s.Metrics[application.MetricTotalTimeMs]
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f-%.2f\t%.2f\t%.2f\t%.0f\t%.0f\t\n",
+			s.Strategy.Name, s.RowCount,
+			total.Mean, total.CILow, total.CIHigh, total.P95,
+			s.Metrics[application.MetricQueryTimeMs].Mean,
+			s.Metrics[application.MetricMemoryBytes].Mean,
+			s.Metrics[application.MetricDBBytesRecv].Mean)
+	}
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METRIC\tBEST\tRUNNER-UP\tP-VALUE\tSIGNIFICANT")
+	for _, c := range result.Comparisons {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.4f\t%t\n", c.Metric, c.Best, c.RunnerUp, c.PValue, c.Significant)
+	}
+	w.Flush()
+
+	fmt.Println()
+	if result.Winner == "" {
+		fmt.Println("No strategy is significantly faster in total time")
+	} else {
+		fmt.Printf("Winner by total time: %s\n", result.Winner)
+	}
+}
+
+// printWriteBenchmark writes the throughput and latency of every write phase
+// per strategy to stdout
+func printWriteBenchmark(results []application.WriteBenchmarkResult) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "STRATEGY\tPHASE\tROWS\tROWS/SEC\tP50 MS\tP99 MS\tWAL BYTES\tTABLE GROWTH BYTES\t")
+	for _, r := range results {
+		phases := []struct {
+			name   string
+			result application.WritePhaseResult
+		}{
+			{"insert", r.Insert},
+			{"update", r.Update},
+			{"delete", r.Delete},
+		}
+		for _, p := range phases {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.0f\t%.3f\t%.3f\t%d\t%d\t\n",
+				r.Strategy.Name, p.name, p.result.Rows, p.result.RowsPerSec(),
+				application.DurationMs(p.result.Latency.P50), application.DurationMs(p.result.Latency.P99),
+				p.result.WALBytes, p.result.TableGrowthBytes)
+		}
+	}
+	w.Flush()
+}
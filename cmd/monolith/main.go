@@ -1,84 +1,78 @@
 package main
 
 import (
-	"context"
+	"database/sql"
+	"fmt"
 	"log"
-	"net/http"
 	"os"
 
 	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
 
-	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/cmd"
-	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+	common_cmd "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/cmd"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
 	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/infrastructure/postgres"
-	invoices_http "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/interfaces/http"
 )
 
 func main() {
-	log.Println("Starting PostgreSQL Virtual Generated Column Test Server")
-
 	// Load .env file if exists
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
 
-	ctx := cmd.Context()
-
-	// Initialize database connection
-	dbConfig := postgres.ConfigFromEnv()
-	db, err := postgres.NewConnection(dbConfig)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	if err := newRootCommand().ExecuteContext(common_cmd.Context()); err != nil {
+		log.Fatal(err)
 	}
-	defer db.Close()
+}
 
-	// Run schema
-	if err := postgres.RunSchema(context.Background(), db); err != nil {
-		log.Fatalf("Failed to run schema: %v", err)
+// newRootCommand creates the command line, with a subcommand per task.
+// Commands run until done or until the process receives an interrupt or
+// termination signal
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "server",
+		Short:         "PostgreSQL virtual generated column test",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 	}
+	root.PersistentFlags().String("rounding", "", "rounding mode of Go totals (default TOTAL_ROUNDING_MODE, or half_away_from_zero)")
+
+	root.AddCommand(
+		newServeCommand(),
+		newMigrateCommand(),
+		newSeedCommand(),
+		newBenchCommand(),
+		newVerifyCommand(),
+	)
+	return root
+}
 
-	// Seed data
-	if err := postgres.Seed(context.Background(), db); err != nil {
-		log.Fatalf("Failed to seed data: %v", err)
+// registryFromFlags registers the total computation strategies, rounding Go
+// totals with the --rounding flag or TOTAL_ROUNDING_MODE
+func registryFromFlags(cmd *cobra.Command) (*postgres.Registry, error) {
+	name, err := cmd.Flags().GetString("rounding")
+	if err != nil {
+		return nil, err
 	}
-
-	// Create repository and service
-	invoicesRepo := postgres.NewRepository(db)
-	invoicesService := application.NewInvoicesService(invoicesRepo)
-
-	// Create router and add routes
-	mux := cmd.CreateRouter()
-	invoices_http.AddRoutes(mux, invoicesService)
-
-	// Get port
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+	if name == "" {
+		name = os.Getenv("TOTAL_ROUNDING_MODE")
 	}
 
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: cmd.WithMiddleware(mux),
+	rounding, err := invoices.ParseRoundingMode(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rounding mode: %w", err)
 	}
+	return postgres.DefaultRegistry(rounding), nil
+}
 
-	go func() {
-		log.Printf("Server listening on :%s", port)
-		log.Println("Routes:")
-		log.Println("  GET /api/invoices/virtual    - Uses PostgreSQL virtual generated column")
-		log.Println("  GET /api/invoices/calculated - Calculates total_cents in Go")
-		log.Println("  GET /api/benchmark           - Compare both approaches (CPU, RAM, network)")
-		log.Println("  GET /api/stats               - Table statistics")
-		log.Println("  GET /health                  - Health check")
-
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("Server failed: %v", err)
-		}
-	}()
-
-	<-ctx.Done()
-	log.Println("Shutting down server...")
-
-	if err := server.Close(); err != nil {
-		log.Printf("Error closing server: %v", err)
+// connect opens the database connection configured by the environment.
+// network counts the bytes exchanged with the database, and may be nil
+func connect(network *postgres.NetworkCounter) (*sql.DB, error) {
+	db, err := postgres.NewConnection(postgres.ConfigFromEnv(), network)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	return db, nil
 }
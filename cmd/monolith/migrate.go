@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/infrastructure/postgres"
+)
+
+func newMigrateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Create the tables, views and triggers of every registered strategy",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			registry, err := registryFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			db, err := connect(nil)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if err := postgres.RunSchema(cmd.Context(), db, registry); err != nil {
+				return fmt.Errorf("failed to run schema: %w", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/infrastructure/postgres"
+)
+
+func newSeedCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Seed every writable table with random invoices",
+		Long: `Seed every writable table with random invoices, until each holds --count rows.
+
+Flags override the SEED_* environment variables. Seeding stops on an interrupt
+or termination signal, and running it again resumes the interrupted run with
+its original settings, writing exactly the batches that are missing.`,
+		Args: cobra.NoArgs,
+		RunE: runSeed,
+	}
+
+	flags := cmd.Flags()
+	flags.Int("count", 0, "rows every seeded table should hold (default SEED_COUNT)")
+	flags.Int("workers", 0, "batches written concurrently (default SEED_WORKERS)")
+	flags.Int("batch-size", 0, "rows per transaction (default SEED_BATCH_SIZE, or the mode's default)")
+	flags.String("mode", "", "copy, generate or insert (default SEED_MODE)")
+	flags.Int64("random-seed", 0, "seed every row is derived from (default SEED_RANDOM_SEED, or 42)")
+	flags.String("profile", "", "built-in profile or JSON file rows are drawn from (default SEED_PROFILE)")
+	flags.Bool("repair-drift", false, "make the rows of every table identical to the first before seeding (default SEED_REPAIR_DRIFT, or false)")
+	return cmd
+}
+
+func runSeed(cmd *cobra.Command, _ []string) error {
+	cfg, err := postgres.SeedConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid seed configuration: %w", err)
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("count") {
+		cfg.Count, _ = flags.GetInt("count")
+	}
+	if flags.Changed("workers") {
+		cfg.Workers, _ = flags.GetInt("workers")
+	}
+	if flags.Changed("batch-size") {
+		cfg.BatchSize, _ = flags.GetInt("batch-size")
+	}
+	if flags.Changed("mode") {
+		mode, _ := flags.GetString("mode")
+		cfg.Mode = postgres.SeedMode(mode)
+	}
+	if flags.Changed("random-seed") {
+		cfg.RandomSeed, _ = flags.GetInt64("random-seed")
+	}
+	if flags.Changed("repair-drift") {
+		cfg.RepairDrift, _ = flags.GetBool("repair-drift")
+	}
+	// SEED_PROFILE is only loaded without the flag, so an invalid one
+	// doesn't stop a seed overriding it
+	if flags.Changed("profile") {
+		name, _ := flags.GetString("profile")
+		cfg.Profile, err = postgres.LoadSeedProfile(name)
+	} else {
+		cfg.Profile, err = postgres.SeedProfileFromEnv()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid seed configuration: %w", err)
+	}
+
+	registry, err := registryFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, err := connect(nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := postgres.Seed(cmd.Context(), db, registry, cfg); err != nil {
+		return fmt.Errorf("failed to seed data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	common_cmd "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/cmd"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/infrastructure/postgres"
+	invoices_http "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/interfaces/http"
+)
+
+// shutdownTimeout is how long in-flight requests may take to finish after
+// the server is told to stop
+const shutdownTimeout = 30 * time.Second
+
+func newServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the HTTP API against an already migrated database",
+		Args:  cobra.NoArgs,
+		RunE:  runServe,
+	}
+	cmd.Flags().String("port", "", "port to listen on (default SERVER_PORT, or 8080)")
+	return cmd
+}
+
+func runServe(cmd *cobra.Command, _ []string) error {
+	log.Println("Starting PostgreSQL Virtual Generated Column Test Server")
+	ctx := cmd.Context()
+
+	registry, err := registryFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Initialize database connection
+	dbNetwork := postgres.NewNetworkCounter()
+	db, err := connect(dbNetwork)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Create repository and service
+	invoicesRepo := postgres.NewRepository(db, registry, dbNetwork)
+	invoicesService := application.NewInvoicesService(invoicesRepo)
+
+	// Sign pagination cursors with CURSOR_SECRET, or a random key when unset
+	cursors, err := invoices_http.NewCursorCodec([]byte(os.Getenv("CURSOR_SECRET")))
+	if err != nil {
+		return fmt.Errorf("failed to create cursor codec: %w", err)
+	}
+	if os.Getenv("CURSOR_SECRET") == "" {
+		log.Println("CURSOR_SECRET not set, pagination cursors will not survive restarts")
+	}
+
+	// Create router and add routes
+	mux := common_cmd.CreateRouter()
+	invoices_http.AddRoutes(mux, invoicesService, cursors)
+
+	// Get port
+	port, err := cmd.Flags().GetString("port")
+	if err != nil {
+		return err
+	}
+	if port == "" {
+		port = os.Getenv("SERVER_PORT")
+	}
+	if port == "" {
+		port = "8080"
+	}
+
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: common_cmd.WithMiddleware(mux),
+	}
+
+	logRoutes(port, invoicesService)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+		return server.Close()
+	}
+	return nil
+}
+
+func logRoutes(port string, service application.InvoicesService) {
+	log.Printf("Server listening on :%s", port)
+	log.Println("Routes:")
+	for _, strategy := range service.Strategies() {
+		log.Printf("  GET /api/invoices/%s - %s", strategy.Name, strategy.Description)
+		log.Printf("  GET /api/invoices/%s/{id} - Single invoice", strategy.Name)
+		if strategy.Refreshable {
+			log.Printf("  POST /api/invoices/%s/refresh - Refresh %s", strategy.Name, strategy.Name)
+		}
+	}
+	log.Println("  POST /api/invoices - Create an invoice in every writable table")
+	log.Println("  PUT|PATCH|DELETE /api/invoices/{id} - Modify an invoice in every writable table")
+	log.Println("  GET /api/strategies - Registered strategies")
+	log.Println("  GET /api/benchmark  - Compare all strategies (CPU, RAM, network)")
+	log.Println("  POST /api/benchmark/write?rows=N - Insert/update/delete throughput per writable strategy")
+	log.Println("  GET /api/stats      - Table statistics")
+	log.Println("  GET /api/verify?database=virtual&application=calculated&limit=N - Compare database and Go totals")
+	log.Println("  GET /health         - Health check")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/infrastructure/postgres"
+)
+
+func newVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Compare the totals computed by the database with the totals computed in Go",
+		Long: `Compare the totals computed by the database with the totals computed in Go
+for every seeded invoice, printing each mismatch. Fails unless the two are equivalent.`,
+		Args: cobra.NoArgs,
+		RunE: runVerify,
+	}
+	cmd.Flags().String("database", "virtual", "strategy computing totals in the database")
+	cmd.Flags().String("application", "calculated", "strategy computing totals in Go")
+	return cmd
+}
+
+func runVerify(cmd *cobra.Command, _ []string) error {
+	database, _ := cmd.Flags().GetString("database")
+	app, _ := cmd.Flags().GetString("application")
+
+	registry, err := registryFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, err := connect(nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service := application.NewInvoicesService(postgres.NewRepository(db, registry, nil))
+
+	log.Printf("Comparing %s totals against %s totals", database, app)
+	result, err := service.VerifyTotals(cmd.Context(), database, app, func(m invoices.TotalMismatch) error {
+		log.Printf("  invoice %d: amount_cents=%d tax_rate=%s database=%d application=%d",
+			m.ID, m.AmountCents, m.TaxRate, m.DatabaseTotalCents, m.ApplicationTotalCents)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify totals: %w", err)
+	}
+
+	log.Printf("Compared %d rows in %v: %d mismatches, %d rows unmatched",
+		result.RowsCompared, result.Duration, result.Mismatches, result.RowsUnmatched)
+	if !result.Equivalent() {
+		return fmt.Errorf("totals of %s and %s rounded %s are not equivalent",
+			database, app, result.Application.Rounding)
+	}
+	log.Println("Totals are equivalent")
+	return nil
+}
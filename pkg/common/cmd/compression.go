@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content encodings supported by CompressionMiddleware
+const (
+	EncodingZstd     = "zstd"
+	EncodingGzip     = "gzip"
+	EncodingIdentity = "identity"
+)
+
+// Encodings lists the supported content encodings, in order of server preference
+var Encodings = []string{EncodingZstd, EncodingGzip, EncodingIdentity}
+
+// NewCompressor returns a writer compressing into w with the given encoding.
+// The caller must close it to flush the remaining compressed data
+func NewCompressor(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingZstd:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return encoder, nil
+	case EncodingGzip:
+		return gzip.NewWriter(w), nil
+	case EncodingIdentity:
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// negotiateEncoding picks the preferred supported encoding from an
+// Accept-Encoding header, ignoring quality values other than q=0
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		rejected := false
+		for _, param := range fields[1:] {
+			if q := strings.ReplaceAll(strings.TrimSpace(param), " ", ""); q == "q=0" || q == "q=0.0" {
+				rejected = true
+			}
+		}
+		if name != "" && !rejected {
+			accepted[name] = true
+		}
+	}
+
+	for _, encoding := range Encodings {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return EncodingIdentity
+}
+
+// compressingResponseWriter compresses the body written through it
+type compressingResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	compressor  io.WriteCloser
+	wroteHeader bool
+}
+
+func (w *compressingResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if header.Get("Content-Encoding") == "" && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified {
+		compressor, err := NewCompressor(w.encoding, w.ResponseWriter)
+		if err == nil {
+			w.compressor = compressor
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *compressingResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.compressor.Write(p)
+}
+
+// Flush writes buffered compressed data to the client
+func (w *compressingResponseWriter) Flush() {
+	if f, ok := w.compressor.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressingResponseWriter) close() error {
+	if w.compressor == nil {
+		return nil
+	}
+	return w.compressor.Close()
+}
+
+// CompressionMiddleware compresses responses with zstd or gzip when the
+// client advertises support for them in Accept-Encoding
+func CompressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == EncodingIdentity {
+			w.Header().Add("Vary", "Accept-Encoding")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressingResponseWriter{ResponseWriter: w, encoding: encoding}
+		next.ServeHTTP(cw, r)
+		if err := cw.close(); err != nil {
+			log.Printf("failed to finish %s response: %v", encoding, err)
+		}
+	})
+}
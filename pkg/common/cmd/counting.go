@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// PayloadBytesTrailer carries the response body size before compression
+	PayloadBytesTrailer = "X-Payload-Bytes"
+
+	// WireBytesTrailer carries the response header and body size as sent to the client
+	WireBytesTrailer = "X-Wire-Bytes"
+)
+
+// countingResponseWriter counts the bytes written through it
+type countingResponseWriter struct {
+	http.ResponseWriter
+	countHeaders bool
+	wroteHeader  bool
+	bytes        int64
+}
+
+func (w *countingResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if w.countHeaders {
+			// Status line plus headers as serialized on an HTTP/1.1 connection
+			w.bytes += int64(len("HTTP/1.1 000 ") + len(http.StatusText(statusCode)) + len("\r\n\r\n"))
+			counter := &byteCounter{}
+			w.Header().Write(counter)
+			w.bytes += counter.n
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush forwards to the underlying writer so streaming handlers keep working
+func (w *countingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// byteCounter is an io.Writer that only counts
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// countingMiddleware reports the bytes written by next in the given trailer.
+// Declaring the trailer up front makes the server use chunked encoding, so
+// the trailer is sent even for responses small enough to get a Content-Length
+func countingMiddleware(next http.Handler, trailer string, countHeaders bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Trailer", trailer)
+		cw := &countingResponseWriter{ResponseWriter: w, countHeaders: countHeaders}
+		next.ServeHTTP(cw, r)
+		w.Header().Set(trailer, strconv.FormatInt(cw.bytes, 10))
+	})
+}
+
+// PayloadBytesMiddleware reports the response body size written by the
+// handler, before any compression, in the X-Payload-Bytes trailer
+func PayloadBytesMiddleware(next http.Handler) http.Handler {
+	return countingMiddleware(next, PayloadBytesTrailer, false)
+}
+
+// WireBytesMiddleware reports the response header and body size sent to the
+// client, after compression, in the X-Wire-Bytes trailer. Chunked transfer
+// framing added by the server is not included
+func WireBytesMiddleware(next http.Handler) http.Handler {
+	return countingMiddleware(next, WireBytesTrailer, true)
+}
+
+// countedCompression wraps handler with compression, counting the payload
+// before it and the bytes on the wire after it
+func countedCompression(handler http.Handler) http.Handler {
+	return WireBytesMiddleware(CompressionMiddleware(PayloadBytesMiddleware(handler)))
+}
+
+// MeasureResponse serves req with handler through the same compression and
+// byte counting middleware as WithMiddleware, discarding the body, and
+// returns the counts it reports in the X-Payload-Bytes and X-Wire-Bytes
+// trailers. The encoding is negotiated from the request's Accept-Encoding
+func MeasureResponse(handler http.Handler, req *http.Request) (payload, wire int64, err error) {
+	w := &discardResponseWriter{header: make(http.Header)}
+	countedCompression(handler).ServeHTTP(w, req)
+	if w.statusCode >= http.StatusBadRequest {
+		return 0, 0, fmt.Errorf("response failed with status %d", w.statusCode)
+	}
+
+	if payload, err = strconv.ParseInt(w.header.Get(PayloadBytesTrailer), 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %w", PayloadBytesTrailer, err)
+	}
+	if wire, err = strconv.ParseInt(w.header.Get(WireBytesTrailer), 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %w", WireBytesTrailer, err)
+	}
+	return payload, wire, nil
+}
+
+// discardResponseWriter keeps the status and headers of a response and
+// discards its body
+type discardResponseWriter struct {
+	header     http.Header
+	statusCode int
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *discardResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
@@ -33,7 +33,8 @@ func CreateRouter() *http.ServeMux {
 	return http.NewServeMux()
 }
 
-// WithMiddleware wraps a handler with logging and recovery middleware
+// WithMiddleware wraps a handler with logging, recovery, compression and
+// byte counting middleware
 func WithMiddleware(handler http.Handler) http.Handler {
-	return RecoveryMiddleware(LoggingMiddleware(handler))
+	return RecoveryMiddleware(LoggingMiddleware(countedCompression(handler)))
 }
@@ -35,3 +35,13 @@ func ErrBadRequest(w http.ResponseWriter, err error) {
 func ErrNotFound(w http.ResponseWriter, err error) {
 	WriteError(w, http.StatusNotFound, err)
 }
+
+// ErrMethodNotAllowed writes a method not allowed error response
+func ErrMethodNotAllowed(w http.ResponseWriter, err error) {
+	WriteError(w, http.StatusMethodNotAllowed, err)
+}
+
+// ErrNotAcceptable writes a not acceptable error response
+func ErrNotAcceptable(w http.ResponseWriter, err error) {
+	WriteError(w, http.StatusNotAcceptable, err)
+}
@@ -0,0 +1,323 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// Built-in benchmark metrics. Database metrics are only recorded when the
+// database provides statement statistics
+const (
+	MetricQueryTimeMs  = "query_time_ms"
+	MetricTotalTimeMs  = "total_time_ms"
+	MetricFirstRowMs   = "time_to_first_row_ms"
+	MetricMemoryBytes  = "memory_bytes"
+	MetricAllocObjects = "alloc_objects"
+	MetricGCCycles     = "gc_cycles"
+	MetricGCPauseMs    = "gc_pause_ms"
+	MetricCPUTimeMs    = "cpu_time_ms"
+	MetricDBBytesRecv  = "db_bytes_received"
+	MetricDBBytesSent  = "db_bytes_sent"
+	MetricDBExecTimeMs = "db_exec_time_ms"
+	MetricDBCPUTimeMs  = "db_cpu_time_ms"
+)
+
+// builtinMetrics lists the built-in metrics in reporting order
+var builtinMetrics = []string{
+	MetricQueryTimeMs,
+	MetricTotalTimeMs,
+	MetricFirstRowMs,
+	MetricMemoryBytes,
+	MetricAllocObjects,
+	MetricGCCycles,
+	MetricGCPauseMs,
+	MetricCPUTimeMs,
+	MetricDBBytesRecv,
+	MetricDBBytesSent,
+	MetricDBExecTimeMs,
+	MetricDBCPUTimeMs,
+}
+
+// BenchmarkOrder controls the order strategies run in within an iteration
+type BenchmarkOrder string
+
+const (
+	// OrderSequential runs strategies in registration order every iteration
+	OrderSequential BenchmarkOrder = "sequential"
+
+	// OrderAlternating reverses the registration order on every other iteration
+	OrderAlternating BenchmarkOrder = "alternating"
+
+	// OrderRandom shuffles the strategies every iteration
+	OrderRandom BenchmarkOrder = "random"
+)
+
+// ParseBenchmarkOrder validates a benchmark order name
+func ParseBenchmarkOrder(s string) (BenchmarkOrder, error) {
+	switch order := BenchmarkOrder(s); order {
+	case OrderSequential, OrderAlternating, OrderRandom:
+		return order, nil
+	default:
+		return "", fmt.Errorf("unknown benchmark order %q", s)
+	}
+}
+
+// MeasureFunc extracts an additional metric from a single run. An error
+// stops the benchmark
+type MeasureFunc func(result InvoicesResult) (float64, error)
+
+// BenchmarkOptions configures a repeated-run benchmark
+type BenchmarkOptions struct {
+	// Query selects the invoices every strategy fetches
+	Query invoices.Query
+
+	Iterations int
+	Warmup     int
+	Order      BenchmarkOrder
+
+	// Explain captures the executed plan of each strategy's fetch query
+	// after the measured iterations
+	Explain bool
+
+	// Stream, when set, streams every run's invoices into it instead of
+	// buffering them, so time to first row reflects the first row read.
+	// Measures are not applied to streamed runs, which keep no invoices
+	Stream RowFunc
+
+	// Measures adds caller-defined metrics, keyed by metric name, that are
+	// summarised alongside the built-in ones
+	Measures map[string]MeasureFunc
+}
+
+// StrategyBenchmark contains the summarised metrics for a single strategy
+type StrategyBenchmark struct {
+	Strategy invoices.Strategy
+	RowCount int
+	Metrics  map[string]Summary
+
+	// Plan is the executed plan of the fetch query, when requested
+	Plan *invoices.QueryPlan
+}
+
+// MetricComparison compares the two best strategies for a metric, where
+// lower values are better
+type MetricComparison struct {
+	Metric      string
+	Best        string
+	RunnerUp    string
+	PValue      float64
+	Significant bool
+}
+
+// BenchmarkResult contains the outcome of a repeated-run benchmark
+type BenchmarkResult struct {
+	Options     BenchmarkOptions
+	Strategies  []StrategyBenchmark
+	Comparisons []MetricComparison
+
+	// Winner is the strategy with the lowest mean total time, or empty if
+	// the difference to the runner-up is not statistically significant
+	Winner string
+}
+
+// RunBenchmark fetches invoices with every strategy for the configured number
+// of warmup runs and measured iterations, and summarises each metric
+func (s InvoicesService) RunBenchmark(ctx context.Context, opts BenchmarkOptions) (BenchmarkResult, error) {
+	if opts.Iterations < 1 {
+		return BenchmarkResult{}, fmt.Errorf("iterations must be at least 1")
+	}
+	if opts.Warmup < 0 {
+		return BenchmarkResult{}, fmt.Errorf("warmup must not be negative")
+	}
+	if err := opts.Query.Validate(); err != nil {
+		return BenchmarkResult{}, err
+	}
+	if opts.Order == "" {
+		opts.Order = OrderRandom
+	}
+
+	strategies := s.repository.Strategies()
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for i := 0; i < opts.Warmup; i++ {
+		for _, strategy := range benchmarkOrder(strategies, opts.Order, i, r) {
+			if _, err := s.runOnce(ctx, strategy.Name, opts); err != nil {
+				return BenchmarkResult{}, err
+			}
+		}
+	}
+
+	samples := make(map[string]map[string][]float64, len(strategies))
+	rowCounts := make(map[string]int, len(strategies))
+	for _, strategy := range strategies {
+		samples[strategy.Name] = make(map[string][]float64)
+	}
+
+	for i := 0; i < opts.Iterations; i++ {
+		for _, strategy := range benchmarkOrder(strategies, opts.Order, i, r) {
+			result, err := s.runOnce(ctx, strategy.Name, opts)
+			if err != nil {
+				return BenchmarkResult{}, err
+			}
+
+			m := samples[strategy.Name]
+			m[MetricQueryTimeMs] = append(m[MetricQueryTimeMs], result.Metrics.QueryTimeMs())
+			m[MetricTotalTimeMs] = append(m[MetricTotalTimeMs], result.Metrics.TotalTimeMs())
+			m[MetricFirstRowMs] = append(m[MetricFirstRowMs], result.Metrics.FirstRowTimeMs())
+			m[MetricMemoryBytes] = append(m[MetricMemoryBytes], float64(result.Metrics.MemoryBytes))
+			m[MetricAllocObjects] = append(m[MetricAllocObjects], float64(result.Metrics.AllocObjects))
+			m[MetricGCCycles] = append(m[MetricGCCycles], float64(result.Metrics.GCCycles))
+			m[MetricGCPauseMs] = append(m[MetricGCPauseMs], DurationMs(result.Metrics.GCPause))
+			m[MetricCPUTimeMs] = append(m[MetricCPUTimeMs], DurationMs(result.Metrics.CPUUser+result.Metrics.CPUSystem))
+			m[MetricDBBytesRecv] = append(m[MetricDBBytesRecv], float64(result.Metrics.DBNetwork.Received))
+			m[MetricDBBytesSent] = append(m[MetricDBBytesSent], float64(result.Metrics.DBNetwork.Sent))
+			if db := result.Metrics.DBStats; db != nil {
+				m[MetricDBExecTimeMs] = append(m[MetricDBExecTimeMs], DurationMs(db.ExecTime))
+				m[MetricDBCPUTimeMs] = append(m[MetricDBCPUTimeMs], DurationMs(db.CPUTime()))
+			}
+			if opts.Stream == nil {
+				for name, measure := range opts.Measures {
+					v, err := measure(result.InvoicesResult)
+					if err != nil {
+						return BenchmarkResult{}, fmt.Errorf("failed to measure %s: %w", name, err)
+					}
+					m[name] = append(m[name], v)
+				}
+			}
+			rowCounts[strategy.Name] = result.rows
+		}
+	}
+
+	result := BenchmarkResult{Options: opts}
+	for _, strategy := range strategies {
+		metrics := make(map[string]Summary, len(samples[strategy.Name]))
+		for name, values := range samples[strategy.Name] {
+			metrics[name] = Summarize(values)
+		}
+		benchmark := StrategyBenchmark{
+			Strategy: strategy,
+			RowCount: rowCounts[strategy.Name],
+			Metrics:  metrics,
+		}
+
+		if opts.Explain {
+			// Explain the statement the runs sent
+			explain := s.repository.ExplainPage
+			if opts.Stream != nil {
+				explain = s.repository.Explain
+			}
+			plan, err := explain(ctx, strategy.Name, opts.Query)
+			if err != nil {
+				return BenchmarkResult{}, err
+			}
+			benchmark.Plan = &plan
+		}
+
+		result.Strategies = append(result.Strategies, benchmark)
+	}
+
+	metricNames := append([]string(nil), builtinMetrics...)
+	for name := range opts.Measures {
+		metricNames = append(metricNames, name)
+	}
+	sort.Strings(metricNames[len(builtinMetrics):])
+
+	for _, name := range metricNames {
+		comparison, ok := compareMetric(result.Strategies, name)
+		if !ok {
+			continue
+		}
+		result.Comparisons = append(result.Comparisons, comparison)
+		if name == MetricTotalTimeMs && comparison.Significant {
+			result.Winner = comparison.Best
+		}
+	}
+
+	return result, nil
+}
+
+// benchmarkRun is the outcome of a single fetch within a benchmark
+type benchmarkRun struct {
+	InvoicesResult
+	rows int
+}
+
+// runOnce fetches invoices once with the named strategy, buffered or
+// streamed depending on opts
+func (s InvoicesService) runOnce(ctx context.Context, strategyName string, opts BenchmarkOptions) (benchmarkRun, error) {
+	if opts.Stream != nil {
+		result, err := s.StreamInvoices(ctx, strategyName, opts.Query, opts.Stream)
+		if err != nil {
+			return benchmarkRun{}, err
+		}
+		return benchmarkRun{
+			InvoicesResult: InvoicesResult{Strategy: result.Strategy, Metrics: result.Metrics},
+			rows:           result.Rows,
+		}, nil
+	}
+
+	result, err := s.GetInvoices(ctx, strategyName, opts.Query)
+	if err != nil {
+		return benchmarkRun{}, err
+	}
+	return benchmarkRun{InvoicesResult: result, rows: len(result.Invoices)}, nil
+}
+
+// benchmarkOrder returns the strategies in the order they run in iteration i
+func benchmarkOrder(strategies []invoices.Strategy, order BenchmarkOrder, i int, r *rand.Rand) []invoices.Strategy {
+	ordered := append([]invoices.Strategy(nil), strategies...)
+
+	switch order {
+	case OrderAlternating:
+		if i%2 == 1 {
+			for a, b := 0, len(ordered)-1; a < b; a, b = a+1, b-1 {
+				ordered[a], ordered[b] = ordered[b], ordered[a]
+			}
+		}
+	case OrderRandom:
+		r.Shuffle(len(ordered), func(a, b int) { ordered[a], ordered[b] = ordered[b], ordered[a] })
+	}
+
+	return ordered
+}
+
+// compareMetric tests whether the strategy with the lowest mean for metric is
+// significantly better than the runner-up. Strategies that did not record
+// the metric are ignored
+func compareMetric(strategies []StrategyBenchmark, metric string) (MetricComparison, bool) {
+	var ranked []StrategyBenchmark
+	for _, s := range strategies {
+		if _, ok := s.Metrics[metric]; ok {
+			ranked = append(ranked, s)
+		}
+	}
+	if len(ranked) < 2 {
+		return MetricComparison{}, false
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Metrics[metric].Mean < ranked[j].Metrics[metric].Mean
+	})
+
+	best, runnerUp := ranked[0], ranked[1]
+	pValue := welchTTest(best.Metrics[metric], runnerUp.Metrics[metric])
+
+	return MetricComparison{
+		Metric:      metric,
+		Best:        best.Strategy.Name,
+		RunnerUp:    runnerUp.Strategy.Name,
+		PValue:      pValue,
+		Significant: pValue < 1-confidenceLevel,
+	}, true
+}
+
+// DurationMs converts a duration to fractional milliseconds, as every
+// millisecond metric is reported
+func DurationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,66 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// WriteResult contains an invoice as written by the database and how long
+// the write took
+type WriteResult struct {
+	Invoice  *invoices.Invoice
+	Duration time.Duration
+}
+
+// CreateInvoice validates input and writes a new invoice to every writable
+// strategy's table
+func (s InvoicesService) CreateInvoice(ctx context.Context, input invoices.InvoiceInput) (WriteResult, error) {
+	if err := input.Validate(); err != nil {
+		return WriteResult{}, err
+	}
+
+	start := time.Now()
+	inv, err := s.repository.Save(ctx, input)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	return WriteResult{Invoice: inv, Duration: time.Since(start)}, nil
+}
+
+// ReplaceInvoice validates input and overwrites every writable field of an
+// existing invoice
+func (s InvoicesService) ReplaceInvoice(ctx context.Context, id invoices.ID, input invoices.InvoiceInput) (WriteResult, error) {
+	if err := input.Validate(); err != nil {
+		return WriteResult{}, err
+	}
+	return s.UpdateInvoice(ctx, id, invoices.InvoiceChanges{
+		CustomerID:  &input.CustomerID,
+		AmountCents: &input.AmountCents,
+		TaxRate:     &input.TaxRate,
+	})
+}
+
+// UpdateInvoice validates changes and applies them to an existing invoice
+func (s InvoicesService) UpdateInvoice(ctx context.Context, id invoices.ID, changes invoices.InvoiceChanges) (WriteResult, error) {
+	if changes.IsEmpty() {
+		return WriteResult{}, fmt.Errorf("%w: no fields to update", invoices.ErrInvalidInvoice)
+	}
+	if err := changes.Validate(); err != nil {
+		return WriteResult{}, err
+	}
+
+	start := time.Now()
+	inv, err := s.repository.Update(ctx, id, changes)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	return WriteResult{Invoice: inv, Duration: time.Since(start)}, nil
+}
+
+// DeleteInvoice removes an invoice from every writable strategy's table
+func (s InvoicesService) DeleteInvoice(ctx context.Context, id invoices.ID) error {
+	return s.repository.Delete(ctx, id)
+}
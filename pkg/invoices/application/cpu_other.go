@@ -0,0 +1,10 @@
+//go:build !unix
+
+package application
+
+import "time"
+
+// processCPUTime is not supported on this platform and always returns zero
+func processCPUTime() (user, system time.Duration) {
+	return 0, 0
+}
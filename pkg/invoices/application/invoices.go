@@ -2,6 +2,8 @@ package application
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"runtime"
 	"time"
 
@@ -20,94 +22,250 @@ func NewInvoicesService(repository invoices.Repository) InvoicesService {
 
 // InvoicesResult contains invoices and performance metrics
 type InvoicesResult struct {
+	Strategy invoices.Strategy
 	Invoices []*invoices.Invoice
-	Metrics  invoices.QueryMetrics
+
+	// Next selects the following page, and is only meaningful when HasMore is set
+	Next    invoices.Query
+	HasMore bool
+
+	Metrics invoices.QueryMetrics
 }
 
-// GetInvoicesWithVirtual retrieves invoices using PostgreSQL virtual generated column
-func (s InvoicesService) GetInvoicesWithVirtual(ctx context.Context, limit int) (InvoicesResult, error) {
-	totalStart := time.Now()
+// Strategies returns the available total computation strategies
+func (s InvoicesService) Strategies() []invoices.Strategy {
+	return s.repository.Strategies()
+}
 
-	var memStart runtime.MemStats
-	runtime.ReadMemStats(&memStart)
+// Strategy returns the strategy registered under name
+func (s InvoicesService) Strategy(name string) (invoices.Strategy, error) {
+	for _, strategy := range s.repository.Strategies() {
+		if strategy.Name == name {
+			return strategy, nil
+		}
+	}
+	return invoices.Strategy{}, fmt.Errorf("%w: %s", invoices.ErrStrategyNotFound, name)
+}
 
-	queryStart := time.Now()
-	invs, err := s.repository.FindAllWithVirtual(ctx, limit)
-	queryDuration := time.Since(queryStart)
+// GetInvoices retrieves the invoices selected by query using the named total
+// computation strategy
+func (s InvoicesService) GetInvoices(ctx context.Context, strategyName string, query invoices.Query) (InvoicesResult, error) {
+	strategy, err := s.Strategy(strategyName)
+	if err != nil {
+		return InvoicesResult{}, err
+	}
+	if err := query.Validate(); err != nil {
+		return InvoicesResult{}, err
+	}
 
+	var page invoices.Page
+	metrics, err := s.measure(ctx, strategy, func(ctx context.Context) error {
+		var err error
+		page, err = s.repository.FindPage(ctx, strategy.Name, query)
+		return err
+	})
 	if err != nil {
 		return InvoicesResult{}, err
 	}
+	metrics.FirstRowDuration = metrics.QueryDuration
 
-	var memEnd runtime.MemStats
-	runtime.ReadMemStats(&memEnd)
+	return InvoicesResult{
+		Strategy: strategy,
+		Invoices: page.Invoices,
+		Next:     query.Next(page),
+		HasMore:  page.HasMore(),
+		Metrics:  metrics,
+	}, nil
+}
 
-	totalDuration := time.Since(totalStart)
+// InvoiceResult contains a single invoice and performance metrics
+type InvoiceResult struct {
+	Strategy invoices.Strategy
+	Invoice  *invoices.Invoice
+	Metrics  invoices.QueryMetrics
+}
 
-	// Handle GC causing memEnd < memStart
-	var memUsed uint64
-	if memEnd.Alloc > memStart.Alloc {
-		memUsed = memEnd.Alloc - memStart.Alloc
+// GetInvoice retrieves the invoice with the given ID using the named total
+// computation strategy
+func (s InvoicesService) GetInvoice(ctx context.Context, strategyName string, id invoices.ID) (InvoiceResult, error) {
+	strategy, err := s.Strategy(strategyName)
+	if err != nil {
+		return InvoiceResult{}, err
 	}
 
-	return InvoicesResult{
-		Invoices: invs,
-		Metrics:  invoices.NewQueryMetrics(queryDuration, totalDuration, memUsed),
-	}, nil
+	var inv *invoices.Invoice
+	metrics, err := s.measure(ctx, strategy, func(ctx context.Context) error {
+		var err error
+		inv, err = s.repository.FindByID(ctx, strategy.Name, id)
+		return err
+	})
+	if err != nil {
+		return InvoiceResult{}, err
+	}
+	metrics.FirstRowDuration = metrics.QueryDuration
+
+	return InvoiceResult{Strategy: strategy, Invoice: inv, Metrics: metrics}, nil
+}
+
+// RowFunc receives the invoices of a streamed fetch one at a time.
+// Returning an error stops the stream
+type RowFunc func(inv *invoices.Invoice) error
+
+// StreamResult contains the outcome of a streamed fetch
+type StreamResult struct {
+	Strategy invoices.Strategy
+	Rows     int
+	Metrics  invoices.QueryMetrics
+}
+
+// StreamInvoices fetches the invoices selected by query using the named total
+// computation strategy and hands each one to fn as it is read, so memory use
+// does not grow with the result set. fn's work counts towards the metrics
+func (s InvoicesService) StreamInvoices(ctx context.Context, strategyName string, query invoices.Query, fn RowFunc) (StreamResult, error) {
+	strategy, err := s.Strategy(strategyName)
+	if err != nil {
+		return StreamResult{}, err
+	}
+	if err := query.Validate(); err != nil {
+		return StreamResult{}, err
+	}
+
+	var rows int
+	var firstRow time.Duration
+	metrics, err := s.measure(ctx, strategy, func(ctx context.Context) error {
+		start := time.Now()
+		return s.repository.Stream(ctx, strategy.Name, query, func(inv *invoices.Invoice) error {
+			// Taken before fn, so encoding and writing the row don't count
+			if rows == 0 {
+				firstRow = time.Since(start)
+			}
+			if err := fn(inv); err != nil {
+				return err
+			}
+			rows++
+			return nil
+		})
+	})
+	if err != nil {
+		return StreamResult{}, err
+	}
+	metrics.FirstRowDuration = firstRow
+
+	return StreamResult{Strategy: strategy, Rows: rows, Metrics: metrics}, nil
 }
 
-// GetInvoicesWithCalculation retrieves invoices and calculates total in Go
-func (s InvoicesService) GetInvoicesWithCalculation(ctx context.Context, limit int) (InvoicesResult, error) {
+// measure runs fetch and records its timing, allocations, CPU time, database
+// traffic and server-side statistics. QueryDuration covers fetch alone, and
+// the traffic is what fetch exchanges under the context it is given
+func (s InvoicesService) measure(ctx context.Context, strategy invoices.Strategy, fetch func(ctx context.Context) error) (invoices.QueryMetrics, error) {
+	// Database statistics are read outside the timed section so the extra
+	// round trips don't count towards the strategy's metrics
+	dbStart, dbStatsErr := s.repository.StatementStats(ctx, strategy.Name)
+	if dbStatsErr != nil && !errors.Is(dbStatsErr, invoices.ErrStatementStatsUnavailable) {
+		return invoices.QueryMetrics{}, dbStatsErr
+	}
+
+	var network invoices.NetworkCounters
+	fetchCtx := invoices.WithNetworkUsage(ctx, &network)
+
 	totalStart := time.Now()
+	cpuUserStart, cpuSystemStart := processCPUTime()
 
 	var memStart runtime.MemStats
 	runtime.ReadMemStats(&memStart)
 
 	queryStart := time.Now()
-	invs, err := s.repository.FindAllWithoutVirtual(ctx, limit)
+	err := fetch(fetchCtx)
 	queryDuration := time.Since(queryStart)
 
 	if err != nil {
-		return InvoicesResult{}, err
+		return invoices.QueryMetrics{}, err
 	}
 
 	var memEnd runtime.MemStats
 	runtime.ReadMemStats(&memEnd)
 
+	cpuUserEnd, cpuSystemEnd := processCPUTime()
 	totalDuration := time.Since(totalStart)
 
-	// Handle GC causing memEnd < memStart
-	var memUsed uint64
-	if memEnd.Alloc > memStart.Alloc {
-		memUsed = memEnd.Alloc - memStart.Alloc
+	// Cumulative counters only grow, so GC during the fetch doesn't hide allocations
+	metrics := invoices.NewQueryMetrics(queryDuration, totalDuration, memEnd.TotalAlloc-memStart.TotalAlloc)
+	metrics.AllocObjects = memEnd.Mallocs - memStart.Mallocs
+	metrics.GCCycles = memEnd.NumGC - memStart.NumGC
+	metrics.GCPause = time.Duration(memEnd.PauseTotalNs - memStart.PauseTotalNs)
+	metrics.CPUUser = cpuUserEnd - cpuUserStart
+	metrics.CPUSystem = cpuSystemEnd - cpuSystemStart
+	metrics.DBNetwork = network
+
+	if dbStatsErr == nil {
+		dbEnd, err := s.repository.StatementStats(ctx, strategy.Name)
+		if err != nil {
+			return invoices.QueryMetrics{}, err
+		}
+		dbStats := dbEnd.Sub(dbStart)
+		metrics.DBStats = &dbStats
 	}
 
-	return InvoicesResult{
-		Invoices: invs,
-		Metrics:  invoices.NewQueryMetrics(queryDuration, totalDuration, memUsed),
-	}, nil
+	return metrics, nil
+}
+
+// ExplainInvoices executes the statement GetInvoices sends for query under
+// EXPLAIN ANALYZE. The query runs again, so call it after the measured fetch
+func (s InvoicesService) ExplainInvoices(ctx context.Context, strategyName string, query invoices.Query) (invoices.QueryPlan, error) {
+	strategy, err := s.Strategy(strategyName)
+	if err != nil {
+		return invoices.QueryPlan{}, err
+	}
+	if err := query.Validate(); err != nil {
+		return invoices.QueryPlan{}, err
+	}
+	return s.repository.ExplainPage(ctx, strategy.Name, query)
+}
+
+// RefreshResult contains the outcome of refreshing a strategy
+type RefreshResult struct {
+	Strategy invoices.Strategy
+	Duration time.Duration
+}
+
+// RefreshStrategy recomputes the precomputed data of the named strategy
+func (s InvoicesService) RefreshStrategy(ctx context.Context, strategyName string) (RefreshResult, error) {
+	strategy, err := s.Strategy(strategyName)
+	if err != nil {
+		return RefreshResult{}, err
+	}
+
+	start := time.Now()
+	if err := s.repository.Refresh(ctx, strategy.Name); err != nil {
+		return RefreshResult{}, err
+	}
+
+	return RefreshResult{Strategy: strategy, Duration: time.Since(start)}, nil
+}
+
+// StrategyStats contains statistics for a single strategy
+type StrategyStats struct {
+	Strategy invoices.Strategy
+	Count    int64
 }
 
 // StatsResult contains table statistics
 type StatsResult struct {
-	WithVirtualCount    int64
-	WithoutVirtualCount int64
+	Strategies []StrategyStats
 }
 
-// GetStats returns row counts for both tables
+// GetStats returns row counts for every strategy
 func (s InvoicesService) GetStats(ctx context.Context) (StatsResult, error) {
-	withCount, err := s.repository.CountWithVirtual(ctx)
-	if err != nil {
-		return StatsResult{}, err
-	}
+	strategies := s.repository.Strategies()
+	result := StatsResult{Strategies: make([]StrategyStats, 0, len(strategies))}
 
-	withoutCount, err := s.repository.CountWithoutVirtual(ctx)
-	if err != nil {
-		return StatsResult{}, err
+	for _, strategy := range strategies {
+		count, err := s.repository.Count(ctx, strategy.Name)
+		if err != nil {
+			return StatsResult{}, err
+		}
+		result.Strategies = append(result.Strategies, StrategyStats{Strategy: strategy, Count: count})
 	}
 
-	return StatsResult{
-		WithVirtualCount:    withCount,
-		WithoutVirtualCount: withoutCount,
-	}, nil
+	return result, nil
 }
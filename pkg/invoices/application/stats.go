@@ -0,0 +1,209 @@
+package application
+
+import (
+	"math"
+	"sort"
+)
+
+// confidenceLevel is the two-sided confidence level used for intervals and
+// significance tests
+const confidenceLevel = 0.95
+
+// Summary describes the distribution of a metric over repeated runs
+type Summary struct {
+	N      int
+	Min    float64
+	Max    float64
+	Mean   float64
+	Median float64
+	P95    float64
+	P99    float64
+	StdDev float64
+
+	// CILow and CIHigh bound the confidence interval for the mean
+	CILow  float64
+	CIHigh float64
+}
+
+// Summarize returns the distribution summary of values
+func Summarize(values []float64) Summary {
+	n := len(values)
+	if n == 0 {
+		return Summary{}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(n)
+
+	var sq float64
+	for _, v := range sorted {
+		sq += (v - mean) * (v - mean)
+	}
+	var stdDev float64
+	if n > 1 {
+		stdDev = math.Sqrt(sq / float64(n-1))
+	}
+
+	summary := Summary{
+		N:      n,
+		Min:    sorted[0],
+		Max:    sorted[n-1],
+		Mean:   mean,
+		Median: percentile(sorted, 50),
+		P95:    percentile(sorted, 95),
+		P99:    percentile(sorted, 99),
+		StdDev: stdDev,
+		CILow:  mean,
+		CIHigh: mean,
+	}
+
+	if n > 1 {
+		margin := studentTQuantile(1-(1-confidenceLevel)/2, float64(n-1)) * stdDev / math.Sqrt(float64(n))
+		summary.CILow = mean - margin
+		summary.CIHigh = mean + margin
+	}
+
+	return summary
+}
+
+// percentile returns the linearly interpolated percentile p of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	pos := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	frac := pos - float64(lower)
+	return sorted[lower] + (sorted[upper]-sorted[lower])*frac
+}
+
+// welchTTest returns the two-sided p-value of Welch's t-test for the
+// difference between the means of a and b. It returns 1 when either sample
+// is too small to estimate variance
+func welchTTest(a, b Summary) float64 {
+	if a.N < 2 || b.N < 2 {
+		return 1
+	}
+
+	va := a.StdDev * a.StdDev / float64(a.N)
+	vb := b.StdDev * b.StdDev / float64(b.N)
+	if va+vb == 0 {
+		if a.Mean == b.Mean {
+			return 1
+		}
+		return 0
+	}
+
+	t := (a.Mean - b.Mean) / math.Sqrt(va+vb)
+	df := (va + vb) * (va + vb) / (va*va/float64(a.N-1) + vb*vb/float64(b.N-1))
+
+	return 2 * (1 - studentTCDF(math.Abs(t), df))
+}
+
+// studentTCDF returns the cumulative distribution function of Student's t
+// distribution with df degrees of freedom
+func studentTCDF(t, df float64) float64 {
+	x := df / (df + t*t)
+	tail := 0.5 * regularizedIncompleteBeta(df/2, 0.5, x)
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// studentTQuantile returns the value t for which studentTCDF(t, df) == p,
+// found by bisection
+func studentTQuantile(p, df float64) float64 {
+	lo, hi := -1000.0, 1000.0
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		if studentTCDF(mid, df) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// regularizedIncompleteBeta evaluates I_x(a, b) using its continued fraction
+// representation (Numerical Recipes, section 6.4)
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+
+		// Even step
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// Odd step
+		num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+
+	return h
+}
@@ -0,0 +1,127 @@
+package application
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestStudentTQuantile(t *testing.T) {
+	// Critical values from standard t-distribution tables
+	tests := []struct {
+		p    float64
+		df   float64
+		want float64
+	}{
+		{p: 0.975, df: 1, want: 12.706},
+		{p: 0.975, df: 2, want: 4.303},
+		{p: 0.975, df: 5, want: 2.571},
+		{p: 0.975, df: 10, want: 2.228},
+		{p: 0.975, df: 30, want: 2.042},
+		{p: 0.95, df: 10, want: 1.812},
+		{p: 0.995, df: 10, want: 3.169},
+		{p: 0.5, df: 10, want: 0},
+		{p: 0.025, df: 10, want: -2.228},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("p=%v,df=%v", tt.p, tt.df), func(t *testing.T) {
+			if got := studentTQuantile(tt.p, tt.df); math.Abs(got-tt.want) > 5e-4 {
+				t.Errorf("studentTQuantile(%v, %v) = %.4f, want %.3f", tt.p, tt.df, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStudentTCDF(t *testing.T) {
+	tests := []struct {
+		t    float64
+		df   float64
+		want float64
+	}{
+		{t: 0, df: 3, want: 0.5},
+		{t: 2.228, df: 10, want: 0.975},
+		{t: -2.228, df: 10, want: 0.025},
+		// df = 1 is the Cauchy distribution, with CDF 1/2 + atan(t)/pi
+		{t: 1, df: 1, want: 0.75},
+		{t: -3, df: 1, want: 0.5 + math.Atan(-3)/math.Pi},
+	}
+
+	for _, tt := range tests {
+		if got := studentTCDF(tt.t, tt.df); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("studentTCDF(%v, %v) = %.6f, want %.6f", tt.t, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestRegularizedIncompleteBeta(t *testing.T) {
+	tests := []struct {
+		a, b, x float64
+		want    float64
+	}{
+		{a: 1, b: 1, x: 0.3, want: 0.3},
+		{a: 3, b: 1, x: 0.5, want: 0.125},
+		{a: 1, b: 4, x: 0.2, want: 1 - math.Pow(0.8, 4)},
+		{a: 5, b: 5, x: 0.5, want: 0.5},
+		{a: 2.5, b: 0.5, x: 0, want: 0},
+		{a: 2.5, b: 0.5, x: 1, want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := regularizedIncompleteBeta(tt.a, tt.b, tt.x); math.Abs(got-tt.want) > 1e-10 {
+			t.Errorf("regularizedIncompleteBeta(%v, %v, %v) = %v, want %v", tt.a, tt.b, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestWelchTTest(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Summary
+		want float64
+	}{
+		{
+			// t = -2/sqrt(0.8) = -2.2361 with 18 degrees of freedom
+			name: "equal variances",
+			a:    Summary{N: 10, Mean: 10, StdDev: 2},
+			b:    Summary{N: 10, Mean: 12, StdDev: 2},
+			want: 0.03825,
+		},
+		{
+			// t = 5/sqrt(6.3) = 1.9920 with 10.72 degrees of freedom
+			name: "unequal variances and sizes",
+			a:    Summary{N: 5, Mean: 20, StdDev: 3},
+			b:    Summary{N: 8, Mean: 15, StdDev: 6},
+			want: 0.07245,
+		},
+		{
+			name: "single run",
+			a:    Summary{N: 1, Mean: 10},
+			b:    Summary{N: 10, Mean: 50, StdDev: 1},
+			want: 1,
+		},
+		{
+			name: "constant and equal",
+			a:    Summary{N: 5, Mean: 3},
+			b:    Summary{N: 5, Mean: 3},
+			want: 1,
+		},
+		{
+			name: "constant and different",
+			a:    Summary{N: 5, Mean: 3},
+			b:    Summary{N: 5, Mean: 4},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := welchTTest(tt.a, tt.b); math.Abs(got-tt.want) > 5e-5 {
+				t.Errorf("welchTTest() = %.5f, want %.5f", got, tt.want)
+			}
+			if got := welchTTest(tt.b, tt.a); math.Abs(got-tt.want) > 5e-5 {
+				t.Errorf("welchTTest() with samples swapped = %.5f, want %.5f", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// TotalsVerification summarizes a comparison of the totals computed by the
+// database and in Go
+type TotalsVerification struct {
+	Database    invoices.Strategy
+	Application invoices.Strategy
+
+	// RowsCompared counts the rows present in both strategies with the same
+	// inputs to the total
+	RowsCompared int64
+
+	// RowsUnmatched counts the rows missing from either strategy, or whose
+	// amount or tax rate differ between them. Their totals are not compared
+	RowsUnmatched int64
+
+	Mismatches int64
+	Duration   time.Duration
+}
+
+// Equivalent reports whether every compared row had the same total, and at
+// least one row was compared
+func (v TotalsVerification) Equivalent() bool {
+	return v.RowsCompared > 0 && v.Mismatches == 0
+}
+
+// VerifyTotals compares the total of every invoice computed by the database
+// strategy with the total computed in Go by the application strategy, and
+// calls fn with each mismatch as it is found. An error from fn stops the
+// verification and is returned
+func (s InvoicesService) VerifyTotals(ctx context.Context, database, application string, fn func(invoices.TotalMismatch) error) (TotalsVerification, error) {
+	dbStrategy, err := s.Strategy(database)
+	if err != nil {
+		return TotalsVerification{}, err
+	}
+	appStrategy, err := s.Strategy(application)
+	if err != nil {
+		return TotalsVerification{}, err
+	}
+
+	result := TotalsVerification{Database: dbStrategy, Application: appStrategy}
+	start := time.Now()
+	err = s.repository.PairTotals(ctx, database, application, func(pair invoices.TotalPair) error {
+		if !pair.Matched() {
+			result.RowsUnmatched++
+			return nil
+		}
+		result.RowsCompared++
+
+		mismatch, ok := pair.Mismatch()
+		if !ok {
+			return nil
+		}
+		result.Mismatches++
+		return fn(mismatch)
+	})
+	if err != nil {
+		return TotalsVerification{}, err
+	}
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
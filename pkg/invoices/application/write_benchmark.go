@@ -0,0 +1,213 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// LatencySummary summarises per-operation latencies
+type LatencySummary struct {
+	Min  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+// summarizeLatencies sorts latencies in place and returns their summary
+func summarizeLatencies(latencies []time.Duration) LatencySummary {
+	if len(latencies) == 0 {
+		return LatencySummary{}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+
+	return LatencySummary{
+		Min:  latencies[0],
+		Mean: total / time.Duration(len(latencies)),
+		P50:  durationPercentile(latencies, 50),
+		P95:  durationPercentile(latencies, 95),
+		P99:  durationPercentile(latencies, 99),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+// durationPercentile returns the nearest-rank percentile p of sorted latencies
+func durationPercentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// WritePhaseResult contains metrics for one write operation type
+type WritePhaseResult struct {
+	Rows             int
+	Duration         time.Duration
+	Latency          LatencySummary
+	WALBytes         int64
+	TableGrowthBytes int64
+	IndexGrowthBytes int64
+}
+
+// RowsPerSec returns the phase throughput
+func (r WritePhaseResult) RowsPerSec() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Rows) / r.Duration.Seconds()
+}
+
+// WriteBenchmarkResult contains write-path metrics for a single strategy
+type WriteBenchmarkResult struct {
+	Strategy invoices.Strategy
+	Insert   WritePhaseResult
+	Update   WritePhaseResult
+	Delete   WritePhaseResult
+}
+
+// RunWriteBenchmark inserts, updates and deletes rows one statement at a
+// time against every writable strategy. Rows inserted by the benchmark are
+// deleted again, so the dataset is left unchanged.
+//
+// Their IDs are reserved like those of saved invoices, and tables are
+// compared without them until every row is deleted. Each strategy reuses
+// them, as it only runs once the previous one deleted its rows
+func (s InvoicesService) RunWriteBenchmark(ctx context.Context, rows int) ([]WriteBenchmarkResult, error) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	first, err := s.repository.ReserveBenchmarkIDs(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []WriteBenchmarkResult
+	for _, strategy := range s.repository.Strategies() {
+		if !strategy.Writable {
+			continue
+		}
+
+		result, err := s.runWriteBenchmark(ctx, strategy, r, first, rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err := s.repository.ReleaseBenchmarkIDs(ctx, first); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// runWriteBenchmark inserts, updates and deletes rows with the IDs reserved
+// from first on in the strategy's table. Rows it inserted are deleted again
+// even if a phase fails or ctx is canceled, as they exist in this table only
+// and would make it drift from the others
+func (s InvoicesService) runWriteBenchmark(ctx context.Context, strategy invoices.Strategy, r *rand.Rand, first invoices.ID, rows int) (result WriteBenchmarkResult, err error) {
+	result.Strategy = strategy
+	ids := make([]invoices.ID, 0, rows)
+	deleted := 0
+
+	defer func() {
+		cleanupCtx := context.WithoutCancel(ctx)
+		for _, id := range ids[deleted:] {
+			if cleanupErr := s.repository.DeleteRow(cleanupCtx, strategy.Name, id); cleanupErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to delete benchmark row %d from %s: %w", id, strategy.Name, cleanupErr))
+			}
+		}
+	}()
+
+	insert := func() error {
+		id := first + invoices.ID(len(ids))
+		if err := s.repository.InsertRow(ctx, strategy.Name, id, r.Int63n(10000)+1, randomAmountCents(r), randomTaxRate(r)); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	}
+	if result.Insert, err = s.measureWritePhase(ctx, strategy, rows, insert); err != nil {
+		return WriteBenchmarkResult{}, err
+	}
+
+	i := 0
+	update := func() error {
+		err := s.repository.UpdateRow(ctx, strategy.Name, ids[i], randomAmountCents(r), randomTaxRate(r))
+		i++
+		return err
+	}
+	if result.Update, err = s.measureWritePhase(ctx, strategy, len(ids), update); err != nil {
+		return WriteBenchmarkResult{}, err
+	}
+
+	deleteRow := func() error {
+		if err := s.repository.DeleteRow(ctx, strategy.Name, ids[deleted]); err != nil {
+			return err
+		}
+		deleted++
+		return nil
+	}
+	if result.Delete, err = s.measureWritePhase(ctx, strategy, len(ids), deleteRow); err != nil {
+		return WriteBenchmarkResult{}, err
+	}
+
+	return result, nil
+}
+
+// measureWritePhase runs op rows times, timing each call, and captures the
+// storage and WAL growth across the whole phase
+func (s InvoicesService) measureWritePhase(ctx context.Context, strategy invoices.Strategy, rows int, op func() error) (WritePhaseResult, error) {
+	before, err := s.repository.StorageSnapshot(ctx, strategy.Name)
+	if err != nil {
+		return WritePhaseResult{}, err
+	}
+
+	latencies := make([]time.Duration, 0, rows)
+	start := time.Now()
+	for n := 0; n < rows; n++ {
+		opStart := time.Now()
+		if err := op(); err != nil {
+			return WritePhaseResult{}, err
+		}
+		latencies = append(latencies, time.Since(opStart))
+	}
+	duration := time.Since(start)
+
+	after, err := s.repository.StorageSnapshot(ctx, strategy.Name)
+	if err != nil {
+		return WritePhaseResult{}, err
+	}
+
+	return WritePhaseResult{
+		Rows:             rows,
+		Duration:         duration,
+		Latency:          summarizeLatencies(latencies),
+		WALBytes:         after.WALBytesSince(before),
+		TableGrowthBytes: after.TableBytes - before.TableBytes,
+		IndexGrowthBytes: after.IndexBytes - before.IndexBytes,
+	}, nil
+}
+
+func randomAmountCents(r *rand.Rand) int64 {
+	return r.Int63n(1000000) + 100
+}
+
+func randomTaxRate(r *rand.Rand) invoices.TaxRate {
+	return invoices.NewTaxRate(int64(r.Intn(25) + 1))
+}
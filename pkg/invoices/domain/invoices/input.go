@@ -0,0 +1,61 @@
+package invoices
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidInvoice is returned when invoice fields fail validation
+var ErrInvalidInvoice = errors.New("invalid invoice")
+
+// maxTaxRate is the largest tax rate a NUMERIC(4,2) column holds
+var maxTaxRate = NewTaxRate(9999)
+
+// InvoiceInput holds the writable fields of an invoice. The total is always
+// derived from them
+type InvoiceInput struct {
+	CustomerID  int64
+	AmountCents int64
+	TaxRate     TaxRate
+}
+
+// Validate checks every field
+func (in InvoiceInput) Validate() error {
+	return InvoiceChanges{
+		CustomerID:  &in.CustomerID,
+		AmountCents: &in.AmountCents,
+		TaxRate:     &in.TaxRate,
+	}.Validate()
+}
+
+// InvoiceChanges holds a partial update of an invoice's writable fields.
+// Nil fields are left unchanged
+type InvoiceChanges struct {
+	CustomerID  *int64
+	AmountCents *int64
+	TaxRate     *TaxRate
+}
+
+// Validate checks the fields being changed. Amounts may be negative, for
+// credits. Tax rates are exact to the column's scale already, so only their
+// range is checked. When both amount and tax rate are given, their total
+// must fit in BIGINT
+func (c InvoiceChanges) Validate() error {
+	if c.CustomerID != nil && *c.CustomerID < 1 {
+		return fmt.Errorf("%w: customer_id must be positive", ErrInvalidInvoice)
+	}
+	if c.TaxRate != nil && (c.TaxRate.Hundredths() < 0 || c.TaxRate.Hundredths() > maxTaxRate.Hundredths()) {
+		return fmt.Errorf("%w: tax_rate must be between 0 and %s", ErrInvalidInvoice, maxTaxRate)
+	}
+	if c.AmountCents != nil && c.TaxRate != nil {
+		if _, err := c.TaxRate.TotalCents(*c.AmountCents, DefaultRoundingMode); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidInvoice, err)
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether no field is being changed
+func (c InvoiceChanges) IsEmpty() bool {
+	return c.CustomerID == nil && c.AmountCents == nil && c.TaxRate == nil
+}
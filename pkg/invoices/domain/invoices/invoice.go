@@ -1,6 +1,9 @@
 package invoices
 
-import "math"
+import "errors"
+
+// ErrInvoiceNotFound is returned when no invoice has the requested ID
+var ErrInvoiceNotFound = errors.New("invoice not found")
 
 // ID represents an invoice identifier
 type ID int64
@@ -10,12 +13,12 @@ type Invoice struct {
 	id          ID
 	customerID  int64
 	amountCents int64
-	taxRate     float64
+	taxRate     TaxRate
 	totalCents  int64
 }
 
 // NewInvoice creates a new Invoice with pre-computed total
-func NewInvoice(id ID, customerID, amountCents int64, taxRate float64, totalCents int64) *Invoice {
+func NewInvoice(id ID, customerID, amountCents int64, taxRate TaxRate, totalCents int64) *Invoice {
 	return &Invoice{
 		id:          id,
 		customerID:  customerID,
@@ -25,16 +28,20 @@ func NewInvoice(id ID, customerID, amountCents int64, taxRate float64, totalCent
 	}
 }
 
-// NewInvoiceWithCalculation creates a new Invoice and calculates total in Go
-func NewInvoiceWithCalculation(id ID, customerID, amountCents int64, taxRate float64) *Invoice {
-	totalCents := int64(math.Round(float64(amountCents) * (1 + taxRate)))
+// NewInvoiceWithCalculation creates a new Invoice and calculates total in Go,
+// rounded to whole cents with mode
+func NewInvoiceWithCalculation(id ID, customerID, amountCents int64, taxRate TaxRate, mode RoundingMode) (*Invoice, error) {
+	totalCents, err := taxRate.TotalCents(amountCents, mode)
+	if err != nil {
+		return nil, err
+	}
 	return &Invoice{
 		id:          id,
 		customerID:  customerID,
 		amountCents: amountCents,
 		taxRate:     taxRate,
 		totalCents:  totalCents,
-	}
+	}, nil
 }
 
 // ID returns the invoice ID
@@ -53,7 +60,7 @@ func (i *Invoice) AmountCents() int64 {
 }
 
 // TaxRate returns the tax rate
-func (i *Invoice) TaxRate() float64 {
+func (i *Invoice) TaxRate() TaxRate {
 	return i.taxRate
 }
 
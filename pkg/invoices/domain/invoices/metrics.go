@@ -1,12 +1,49 @@
 package invoices
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrStatementStatsUnavailable is returned when the database does not track
+// per-statement execution statistics
+var ErrStatementStatsUnavailable = errors.New("statement statistics unavailable")
 
 // QueryMetrics holds performance metrics for a query operation
 type QueryMetrics struct {
 	QueryDuration time.Duration
 	TotalDuration time.Duration
-	MemoryBytes   uint64
+
+	// FirstRowDuration is the time from the start of the query until the
+	// first row was read, before the consumer handles it. For buffered
+	// fetches it equals QueryDuration, as no row is available before the
+	// whole result is read
+	FirstRowDuration time.Duration
+
+	// MemoryBytes and AllocObjects are the heap bytes and objects allocated
+	// during the operation, from cumulative counters unaffected by GC
+	MemoryBytes  uint64
+	AllocObjects uint64
+
+	// GCCycles and GCPause are the garbage collections completed, and the
+	// stop-the-world pause time they caused, during the operation
+	GCCycles uint32
+	GCPause  time.Duration
+
+	// CPUUser and CPUSystem are the process CPU time spent during the
+	// operation. They cover all goroutines, including the garbage collector
+	CPUUser   time.Duration
+	CPUSystem time.Duration
+
+	// DBNetwork holds the bytes exchanged with the database during the
+	// operation, on the wire as seen by the driver, over the connections the
+	// operation used
+	DBNetwork NetworkCounters
+
+	// DBStats holds server-side statistics for the fetch query, or nil when
+	// the database does not provide them
+	DBStats *StatementStats
 }
 
 // NewQueryMetrics creates a new QueryMetrics instance
@@ -28,7 +65,100 @@ func (m QueryMetrics) TotalTimeMs() float64 {
 	return float64(m.TotalDuration.Microseconds()) / 1000
 }
 
-// CPUTimeNs returns CPU time in nanoseconds
+// FirstRowTimeMs returns the time to the first row in milliseconds
+func (m QueryMetrics) FirstRowTimeMs() float64 {
+	return float64(m.FirstRowDuration.Microseconds()) / 1000
+}
+
+// CPUTimeNs returns process CPU time (user + system) in nanoseconds
 func (m QueryMetrics) CPUTimeNs() int64 {
-	return m.TotalDuration.Nanoseconds()
+	return (m.CPUUser + m.CPUSystem).Nanoseconds()
+}
+
+// StatementStats holds cumulative server-side execution statistics for a statement
+type StatementStats struct {
+	Calls    int64
+	ExecTime time.Duration
+	IOTime   time.Duration
+}
+
+// Sub returns the statistics accumulated between earlier and s
+func (s StatementStats) Sub(earlier StatementStats) StatementStats {
+	return StatementStats{
+		Calls:    s.Calls - earlier.Calls,
+		ExecTime: s.ExecTime - earlier.ExecTime,
+		IOTime:   s.IOTime - earlier.IOTime,
+	}
+}
+
+// CPUTime approximates server CPU time as execution time minus block I/O
+// time. I/O time is only tracked when track_io_timing is enabled
+func (s StatementStats) CPUTime() time.Duration {
+	if s.IOTime > s.ExecTime {
+		return 0
+	}
+	return s.ExecTime - s.IOTime
+}
+
+// StorageSnapshot captures the on-disk size of a strategy's table and the
+// server's write-ahead log position at a point in time
+type StorageSnapshot struct {
+	TableBytes  int64
+	IndexBytes  int64
+	WALPosition int64
+}
+
+// WALBytesSince returns the WAL bytes generated between earlier and s.
+// The WAL is shared by the whole server, so concurrent activity is included
+func (s StorageSnapshot) WALBytesSince(earlier StorageSnapshot) int64 {
+	return s.WALPosition - earlier.WALPosition
+}
+
+// QueryPlan holds the executed plan of a fetch query as reported by
+// EXPLAIN ANALYZE
+type QueryPlan struct {
+	PlanningTime     time.Duration
+	ExecutionTime    time.Duration
+	SharedHitBlocks  int64
+	SharedReadBlocks int64
+
+	// Plan is the plan tree in the database's JSON format
+	Plan []byte
+}
+
+// NetworkCounters holds cumulative bytes exchanged with the database
+type NetworkCounters struct {
+	Received int64
+	Sent     int64
+}
+
+// Add returns the bytes exchanged in both c and other
+func (c NetworkCounters) Add(other NetworkCounters) NetworkCounters {
+	return NetworkCounters{
+		Received: c.Received + other.Received,
+		Sent:     c.Sent + other.Sent,
+	}
+}
+
+// Sub returns the bytes exchanged between earlier and c
+func (c NetworkCounters) Sub(earlier NetworkCounters) NetworkCounters {
+	return NetworkCounters{
+		Received: c.Received - earlier.Received,
+		Sent:     c.Sent - earlier.Sent,
+	}
+}
+
+type networkUsageKey struct{}
+
+// WithNetworkUsage returns a context under which repositories add the bytes
+// each call exchanges over its own database connection to usage. usage must
+// not be shared by concurrent calls
+func WithNetworkUsage(ctx context.Context, usage *NetworkCounters) context.Context {
+	return context.WithValue(ctx, networkUsageKey{}, usage)
+}
+
+// NetworkUsage returns the usage set with WithNetworkUsage, or nil
+func NetworkUsage(ctx context.Context) *NetworkCounters {
+	usage, _ := ctx.Value(networkUsageKey{}).(*NetworkCounters)
+	return usage
 }
@@ -0,0 +1,24 @@
+package invoices
+
+// Page is a single page of invoices from a keyset-paginated fetch
+type Page struct {
+	Invoices []*Invoice
+
+	// NextAfterID is the AfterID selecting the following page, or zero when
+	// this is the last page
+	NextAfterID ID
+}
+
+// HasMore reports whether invoices follow this page
+func (p Page) HasMore() bool {
+	return p.NextAfterID > 0
+}
+
+// Next returns the query selecting the page after the one q produced,
+// keeping q's filters and limit. Offset only applies to the first page
+func (q Query) Next(p Page) Query {
+	next := q
+	next.Offset = 0
+	next.AfterID = p.NextAfterID
+	return next
+}
@@ -0,0 +1,53 @@
+package invoices
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidQuery is returned when a query's filters or paging are inconsistent
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query selects and pages the invoices returned by a fetch. Results are
+// always ordered by id. Nil filters are not applied
+type Query struct {
+	Limit  int
+	Offset int
+
+	// AfterID restricts results to invoices with an id greater than it.
+	// Zero disables the restriction
+	AfterID ID
+
+	CustomerID *int64
+
+	MinAmountCents *int64
+	MaxAmountCents *int64
+
+	MinTaxRate *TaxRate
+	MaxTaxRate *TaxRate
+}
+
+// NewQuery creates a Query returning the first limit invoices
+func NewQuery(limit int) Query {
+	return Query{Limit: limit}
+}
+
+// Validate checks the paging bounds and that every range is non-empty
+func (q Query) Validate() error {
+	if q.Limit < 1 {
+		return fmt.Errorf("%w: limit must be at least 1", ErrInvalidQuery)
+	}
+	if q.Offset < 0 {
+		return fmt.Errorf("%w: offset must not be negative", ErrInvalidQuery)
+	}
+	if q.AfterID < 0 {
+		return fmt.Errorf("%w: after_id must not be negative", ErrInvalidQuery)
+	}
+	if q.MinAmountCents != nil && q.MaxAmountCents != nil && *q.MinAmountCents > *q.MaxAmountCents {
+		return fmt.Errorf("%w: min_amount_cents is greater than max_amount_cents", ErrInvalidQuery)
+	}
+	if q.MinTaxRate != nil && q.MaxTaxRate != nil && q.MinTaxRate.Hundredths() > q.MaxTaxRate.Hundredths() {
+		return fmt.Errorf("%w: min_tax_rate is greater than max_tax_rate", ErrInvalidQuery)
+	}
+	return nil
+}
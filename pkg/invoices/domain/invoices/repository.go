@@ -2,18 +2,92 @@ package invoices
 
 import "context"
 
-// Repository defines the interface for invoice persistence
+// Repository defines the interface for invoice persistence. Fetches add the
+// bytes they exchange with the database to the NetworkUsage of their context
 type Repository interface {
-	// FindAllWithVirtual returns invoices with pre-computed total from DB
-	FindAllWithVirtual(ctx context.Context, limit int) ([]*Invoice, error)
+	// Strategies returns the registered total computation strategies in registration order
+	Strategies() []Strategy
 
-	// FindAllWithoutVirtual returns invoices without pre-computed total
-	// The caller is responsible for calculating the total
-	FindAllWithoutVirtual(ctx context.Context, limit int) ([]*Invoice, error)
+	// FindAll returns the invoices selected by query, fetched using the named
+	// strategy. Returns ErrStrategyNotFound if the strategy is not registered
+	FindAll(ctx context.Context, strategy string, query Query) ([]*Invoice, error)
 
-	// CountWithVirtual returns the count of invoices in the virtual table
-	CountWithVirtual(ctx context.Context) (int64, error)
+	// FindPage returns a page of at most query.Limit invoices selected by
+	// query, fetched using the named strategy, and where the next page starts
+	FindPage(ctx context.Context, strategy string, query Query) (Page, error)
 
-	// CountWithoutVirtual returns the count of invoices in the non-virtual table
-	CountWithoutVirtual(ctx context.Context) (int64, error)
+	// Stream fetches the invoices selected by query using the named strategy
+	// and calls fn with each one as it is read. An error from fn stops the
+	// stream and is returned
+	Stream(ctx context.Context, strategy string, query Query, fn func(*Invoice) error) error
+
+	// FindByID returns the invoice with the given ID, fetched using the named
+	// strategy. Returns ErrInvoiceNotFound if there is no such invoice
+	FindByID(ctx context.Context, strategy string, id ID) (*Invoice, error)
+
+	// Explain executes the statement Stream sends for query under EXPLAIN
+	// ANALYZE and returns the executed plan
+	Explain(ctx context.Context, strategy string, query Query) (QueryPlan, error)
+
+	// ExplainPage executes the statement FindPage sends for query under
+	// EXPLAIN ANALYZE and returns the executed plan
+	ExplainPage(ctx context.Context, strategy string, query Query) (QueryPlan, error)
+
+	// Count returns the count of invoices visible to the named strategy
+	Count(ctx context.Context, strategy string) (int64, error)
+
+	// Refresh recomputes the precomputed data of the named strategy.
+	// Returns ErrStrategyNotRefreshable if the strategy is not refreshable
+	Refresh(ctx context.Context, strategy string) error
+
+	// ReserveBenchmarkIDs takes count consecutive IDs for benchmark rows from
+	// the sequence assigning invoice IDs, and returns the first. Tables are
+	// compared without them until they are released
+	ReserveBenchmarkIDs(ctx context.Context, count int) (ID, error)
+
+	// ReleaseBenchmarkIDs releases the IDs reserved from first on, once no
+	// table holds rows with them
+	ReleaseBenchmarkIDs(ctx context.Context, first ID) error
+
+	// InsertRow writes a single invoice with a reserved ID to the named
+	// writable strategy's table
+	InsertRow(ctx context.Context, strategy string, id ID, customerID, amountCents int64, taxRate TaxRate) error
+
+	// UpdateRow sets amount_cents and tax_rate of a single invoice in the named
+	// writable strategy's table
+	UpdateRow(ctx context.Context, strategy string, id ID, amountCents int64, taxRate TaxRate) error
+
+	// DeleteRow removes a single invoice from the named writable strategy's table
+	DeleteRow(ctx context.Context, strategy string, id ID) error
+
+	// Save writes a new invoice to every writable strategy's table in one
+	// transaction, under the same ID, and returns it with the total computed
+	// by the database
+	Save(ctx context.Context, input InvoiceInput) (*Invoice, error)
+
+	// Update applies changes to the invoice with the given ID in every
+	// writable strategy's table in one transaction, and returns the updated
+	// invoice. Returns ErrInvoiceNotFound if there is no such invoice
+	Update(ctx context.Context, id ID, changes InvoiceChanges) (*Invoice, error)
+
+	// Delete removes the invoice with the given ID from every writable
+	// strategy's table in one transaction. Returns ErrInvoiceNotFound if there
+	// is no such invoice
+	Delete(ctx context.Context, id ID) error
+
+	// PairTotals joins the rows of the database strategy, which computes
+	// totals in the database, with the rows of the application strategy,
+	// which computes them in Go, by ID and calls fn with each pair in ID
+	// order. Returns ErrInvalidComparison if either strategy computes totals
+	// elsewhere
+	PairTotals(ctx context.Context, database, application string, fn func(TotalPair) error) error
+
+	// StatementStats returns cumulative server-side statistics for the named
+	// strategy's fetch query. Returns ErrStatementStatsUnavailable if the
+	// database does not track them
+	StatementStats(ctx context.Context, strategy string) (StatementStats, error)
+
+	// StorageSnapshot returns the current size of the named strategy's table
+	// and the WAL position
+	StorageSnapshot(ctx context.Context, strategy string) (StorageSnapshot, error)
 }
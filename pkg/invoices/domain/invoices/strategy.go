@@ -0,0 +1,45 @@
+package invoices
+
+import "errors"
+
+// ErrStrategyNotFound is returned when no strategy is registered under a name
+var ErrStrategyNotFound = errors.New("strategy not found")
+
+// ErrStrategyNotRefreshable is returned when refreshing a strategy that has no precomputed data
+var ErrStrategyNotRefreshable = errors.New("strategy is not refreshable")
+
+// ErrStrategyNotWritable is returned when writing through a read-only strategy
+var ErrStrategyNotWritable = errors.New("strategy is not writable")
+
+// Computation describes where an invoice total is computed
+type Computation string
+
+const (
+	// ComputedOnWrite means the database computes the total when a row is written
+	ComputedOnWrite Computation = "database_on_write"
+
+	// ComputedOnRead means the database computes the total while reading
+	ComputedOnRead Computation = "database_on_read"
+
+	// ComputedInApplication means the total is computed in Go after fetching
+	ComputedInApplication Computation = "application"
+)
+
+// Strategy describes one approach to producing invoice totals
+type Strategy struct {
+	Name        string
+	Description string
+	Computation Computation
+
+	// Rounding is how totals computed in Go are rounded to whole cents. It is
+	// empty for strategies computing totals in the database
+	Rounding RoundingMode
+
+	// Writable reports whether the strategy owns a table that accepts writes.
+	// Strategies reading from another strategy's table or a view are read-only
+	Writable bool
+
+	// Refreshable reports whether the strategy serves precomputed data that
+	// must be refreshed to pick up writes, such as a materialized view
+	Refreshable bool
+}
@@ -0,0 +1,231 @@
+package invoices
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidTaxRate is returned when a tax rate cannot be represented exactly
+var ErrInvalidTaxRate = errors.New("invalid tax rate")
+
+// ErrTotalOutOfRange is returned when a total does not fit in int64 cents,
+// where Postgres would fail with bigint out of range
+var ErrTotalOutOfRange = errors.New("total out of range")
+
+// taxRateScale is the number of fractional digits of a tax rate, the scale
+// of the NUMERIC(4,2) tax_rate columns
+const taxRateScale = 2
+
+// taxRateUnit is the number of hundredths in a tax rate of 1
+const taxRateUnit = 100
+
+// TaxRate is an exact decimal tax rate with two fractional digits, such as
+// 0.13 for 13%. The zero value is a rate of 0
+type TaxRate struct {
+	hundredths int64
+}
+
+// NewTaxRate creates a TaxRate of hundredths/100
+func NewTaxRate(hundredths int64) TaxRate {
+	return TaxRate{hundredths: hundredths}
+}
+
+// ParseTaxRate parses a decimal such as "0.13", as Postgres prints NUMERIC
+// values. More fractional digits are only accepted if they are zeros, as
+// anything else would need rounding. Exponents are rejected
+func ParseTaxRate(s string) (TaxRate, error) {
+	digits := s
+	negative := false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(frac) {
+		return TaxRate{}, fmt.Errorf("%w: %q is not a decimal number", ErrInvalidTaxRate, s)
+	}
+	if len(frac) > taxRateScale {
+		if strings.Trim(frac[taxRateScale:], "0") != "" {
+			return TaxRate{}, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidTaxRate, s, taxRateScale)
+		}
+		frac = frac[:taxRateScale]
+	}
+	frac += strings.Repeat("0", taxRateScale-len(frac))
+
+	hundredths, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return TaxRate{}, fmt.Errorf("%w: %q is out of range", ErrInvalidTaxRate, s)
+	}
+	if negative {
+		hundredths = -hundredths
+	}
+	return TaxRate{hundredths: hundredths}, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Hundredths returns the rate in hundredths, such as 13 for 0.13
+func (r TaxRate) Hundredths() int64 {
+	return r.hundredths
+}
+
+// Float64 returns the nearest float64 to the rate, for formats without
+// decimals. It must not be used for money math
+func (r TaxRate) Float64() float64 {
+	return float64(r.hundredths) / taxRateUnit
+}
+
+// String formats the rate with two fractional digits, like Postgres does
+func (r TaxRate) String() string {
+	sign := ""
+	abs := uint64(r.hundredths)
+	if r.hundredths < 0 {
+		sign = "-"
+		abs = -abs
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/taxRateUnit, abs%taxRateUnit)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (r TaxRate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (r *TaxRate) UnmarshalText(text []byte) error {
+	parsed, err := ParseTaxRate(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// MarshalJSON encodes the rate as a string, so clients don't read it into a float
+func (r TaxRate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+// UnmarshalJSON accepts the rate as a string or a number. Numbers are parsed
+// from their literal text, never through a float
+func (r *TaxRate) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	return r.UnmarshalText([]byte(text))
+}
+
+// TotalCents returns amountCents * (1 + r), rounded to whole cents with mode.
+// The product is computed exactly in 128 bits, so no amount loses precision
+func (r TaxRate) TotalCents(amountCents int64, mode RoundingMode) (int64, error) {
+	factor := taxRateUnit + r.hundredths
+	negative := (amountCents < 0) != (factor < 0)
+
+	hi, lo := bits.Mul64(absUint64(amountCents), absUint64(factor))
+	if hi >= taxRateUnit {
+		return 0, fmt.Errorf("%w: %d * (1 + %s)", ErrTotalOutOfRange, amountCents, r)
+	}
+	quo, rem := bits.Div64(hi, lo, taxRateUnit)
+
+	if mode.roundsAway(quo, rem, taxRateUnit, negative) {
+		quo++
+	}
+	if negative {
+		if quo > uint64(math.MaxInt64)+1 {
+			return 0, fmt.Errorf("%w: %d * (1 + %s)", ErrTotalOutOfRange, amountCents, r)
+		}
+		return int64(-quo), nil
+	}
+	if quo > math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %d * (1 + %s)", ErrTotalOutOfRange, amountCents, r)
+	}
+	return int64(quo), nil
+}
+
+func absUint64(n int64) uint64 {
+	if n < 0 {
+		return -uint64(n)
+	}
+	return uint64(n)
+}
+
+// RoundingMode decides how totals computed in Go are rounded to whole cents
+type RoundingMode string
+
+const (
+	// RoundHalfAwayFromZero rounds ties away from zero, like ROUND on NUMERIC
+	// in Postgres
+	RoundHalfAwayFromZero RoundingMode = "half_away_from_zero"
+
+	// RoundHalfEven rounds ties to the even neighbor, also known as banker's rounding
+	RoundHalfEven RoundingMode = "half_even"
+
+	// RoundDown truncates towards zero
+	RoundDown RoundingMode = "down"
+
+	// RoundUp rounds away from zero
+	RoundUp RoundingMode = "up"
+
+	// RoundFloor rounds towards negative infinity
+	RoundFloor RoundingMode = "floor"
+
+	// RoundCeiling rounds towards positive infinity
+	RoundCeiling RoundingMode = "ceiling"
+)
+
+// DefaultRoundingMode matches the totals computed by the database
+const DefaultRoundingMode = RoundHalfAwayFromZero
+
+// RoundingModes lists the supported rounding modes
+var RoundingModes = []RoundingMode{
+	RoundHalfAwayFromZero, RoundHalfEven, RoundDown, RoundUp, RoundFloor, RoundCeiling,
+}
+
+// ParseRoundingMode returns the rounding mode with the given name, or
+// DefaultRoundingMode if name is empty
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	if name == "" {
+		return DefaultRoundingMode, nil
+	}
+	for _, mode := range RoundingModes {
+		if string(mode) == name {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown rounding mode %q, expected one of %v", name, RoundingModes)
+}
+
+// roundsAway reports whether the magnitude quo with remainder rem of divisor
+// must be incremented. negative is the sign of the exact value
+func (m RoundingMode) roundsAway(quo, rem, divisor uint64, negative bool) bool {
+	if rem == 0 {
+		return false
+	}
+	switch m {
+	case RoundHalfEven:
+		return rem*2 > divisor || (rem*2 == divisor && quo%2 == 1)
+	case RoundDown:
+		return false
+	case RoundUp:
+		return true
+	case RoundFloor:
+		return negative
+	case RoundCeiling:
+		return !negative
+	default:
+		return rem*2 >= divisor
+	}
+}
@@ -0,0 +1,224 @@
+package invoices
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseTaxRate(t *testing.T) {
+	tests := []struct {
+		input      string
+		hundredths int64
+		err        error
+	}{
+		{input: "0.13", hundredths: 13},
+		{input: "7", hundredths: 700},
+		{input: "7.5", hundredths: 750},
+		{input: "07.50", hundredths: 750},
+		{input: "5.", hundredths: 500},
+		{input: "0.120", hundredths: 12},
+		{input: "0.1300000", hundredths: 13},
+		{input: "99.99", hundredths: 9999},
+		{input: "-0.13", hundredths: -13},
+		{input: "-7.5", hundredths: -750},
+		{input: "+0.13", hundredths: 13},
+		{input: "0.125", err: ErrInvalidTaxRate},
+		{input: "0.1301", err: ErrInvalidTaxRate},
+		{input: "", err: ErrInvalidTaxRate},
+		{input: "-", err: ErrInvalidTaxRate},
+		{input: ".5", err: ErrInvalidTaxRate},
+		{input: "1e2", err: ErrInvalidTaxRate},
+		{input: "0.1.3", err: ErrInvalidTaxRate},
+		{input: "abc", err: ErrInvalidTaxRate},
+		{input: " 0.13", err: ErrInvalidTaxRate},
+		{input: "99999999999999999999", err: ErrInvalidTaxRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			rate, err := ParseTaxRate(tt.input)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("ParseTaxRate(%q) error = %v, want %v", tt.input, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTaxRate(%q) error = %v", tt.input, err)
+			}
+			if rate.Hundredths() != tt.hundredths {
+				t.Errorf("ParseTaxRate(%q) = %d hundredths, want %d", tt.input, rate.Hundredths(), tt.hundredths)
+			}
+		})
+	}
+}
+
+func TestTaxRateString(t *testing.T) {
+	tests := []struct {
+		hundredths int64
+		want       string
+	}{
+		{hundredths: 0, want: "0.00"},
+		{hundredths: 13, want: "0.13"},
+		{hundredths: 750, want: "7.50"},
+		{hundredths: -5, want: "-0.05"},
+		{hundredths: 9999, want: "99.99"},
+	}
+
+	for _, tt := range tests {
+		if got := NewTaxRate(tt.hundredths).String(); got != tt.want {
+			t.Errorf("NewTaxRate(%d).String() = %q, want %q", tt.hundredths, got, tt.want)
+		}
+	}
+}
+
+func TestTaxRateJSON(t *testing.T) {
+	for _, input := range []string{`"0.13"`, `0.13`} {
+		var rate TaxRate
+		if err := rate.UnmarshalJSON([]byte(input)); err != nil {
+			t.Fatalf("UnmarshalJSON(%s) error = %v", input, err)
+		}
+		if rate.Hundredths() != 13 {
+			t.Errorf("UnmarshalJSON(%s) = %d hundredths, want 13", input, rate.Hundredths())
+		}
+		data, err := rate.MarshalJSON()
+		if err != nil {
+			t.Fatalf("MarshalJSON() error = %v", err)
+		}
+		if string(data) != `"0.13"` {
+			t.Errorf("MarshalJSON() = %s, want %q", data, "0.13")
+		}
+	}
+}
+
+func TestTaxRateTotalCents(t *testing.T) {
+	// want lists the total per rounding mode
+	tests := []struct {
+		name        string
+		amountCents int64
+		hundredths  int64
+		want        map[RoundingMode]int64
+	}{
+		{
+			name:        "exact",
+			amountCents: 100,
+			hundredths:  13,
+			want: map[RoundingMode]int64{
+				RoundHalfAwayFromZero: 113, RoundHalfEven: 113, RoundDown: 113,
+				RoundUp: 113, RoundFloor: 113, RoundCeiling: 113,
+			},
+		},
+		{
+			// 56.5 rounds to the even 56
+			name:        "tie below even",
+			amountCents: 50,
+			hundredths:  13,
+			want: map[RoundingMode]int64{
+				RoundHalfAwayFromZero: 57, RoundHalfEven: 56, RoundDown: 56,
+				RoundUp: 57, RoundFloor: 56, RoundCeiling: 57,
+			},
+		},
+		{
+			// 169.5 rounds to the even 170
+			name:        "tie above even",
+			amountCents: 150,
+			hundredths:  13,
+			want: map[RoundingMode]int64{
+				RoundHalfAwayFromZero: 170, RoundHalfEven: 170, RoundDown: 169,
+				RoundUp: 170, RoundFloor: 169, RoundCeiling: 170,
+			},
+		},
+		{
+			// -56.5
+			name:        "negative tie",
+			amountCents: -50,
+			hundredths:  13,
+			want: map[RoundingMode]int64{
+				RoundHalfAwayFromZero: -57, RoundHalfEven: -56, RoundDown: -56,
+				RoundUp: -57, RoundFloor: -57, RoundCeiling: -56,
+			},
+		},
+		{
+			// 1.01
+			name:        "below half",
+			amountCents: 1,
+			hundredths:  1,
+			want: map[RoundingMode]int64{
+				RoundHalfAwayFromZero: 1, RoundHalfEven: 1, RoundDown: 1,
+				RoundUp: 2, RoundFloor: 1, RoundCeiling: 2,
+			},
+		},
+		{
+			name:        "zero rate at max",
+			amountCents: math.MaxInt64,
+			hundredths:  0,
+			want: map[RoundingMode]int64{
+				RoundHalfAwayFromZero: math.MaxInt64, RoundHalfEven: math.MaxInt64, RoundDown: math.MaxInt64,
+				RoundUp: math.MaxInt64, RoundFloor: math.MaxInt64, RoundCeiling: math.MaxInt64,
+			},
+		},
+		{
+			name:        "zero rate at min",
+			amountCents: math.MinInt64,
+			hundredths:  0,
+			want: map[RoundingMode]int64{
+				RoundHalfAwayFromZero: math.MinInt64, RoundHalfEven: math.MinInt64, RoundDown: math.MinInt64,
+				RoundUp: math.MinInt64, RoundFloor: math.MinInt64, RoundCeiling: math.MinInt64,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, mode := range RoundingModes {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				got, err := NewTaxRate(tt.hundredths).TotalCents(tt.amountCents, mode)
+				if err != nil {
+					t.Fatalf("TotalCents(%d) error = %v", tt.amountCents, err)
+				}
+				if want := tt.want[mode]; got != want {
+					t.Errorf("TotalCents(%d) at %s = %d, want %d", tt.amountCents, NewTaxRate(tt.hundredths), got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestTaxRateTotalCentsOutOfRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		amountCents int64
+		hundredths  int64
+	}{
+		{name: "max", amountCents: math.MaxInt64, hundredths: 1},
+		{name: "min", amountCents: math.MinInt64, hundredths: 1},
+		{name: "max at highest rate", amountCents: math.MaxInt64 / 50, hundredths: 9999},
+		{name: "min at highest rate", amountCents: math.MinInt64 / 50, hundredths: 9999},
+	}
+
+	for _, tt := range tests {
+		for _, mode := range RoundingModes {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				_, err := NewTaxRate(tt.hundredths).TotalCents(tt.amountCents, mode)
+				if !errors.Is(err, ErrTotalOutOfRange) {
+					t.Errorf("TotalCents(%d) error = %v, want %v", tt.amountCents, err, ErrTotalOutOfRange)
+				}
+			})
+		}
+	}
+}
+
+func TestParseRoundingMode(t *testing.T) {
+	for _, mode := range RoundingModes {
+		got, err := ParseRoundingMode(string(mode))
+		if err != nil || got != mode {
+			t.Errorf("ParseRoundingMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if got, err := ParseRoundingMode(""); err != nil || got != DefaultRoundingMode {
+		t.Errorf("ParseRoundingMode(\"\") = %q, %v, want %q", got, err, DefaultRoundingMode)
+	}
+	if _, err := ParseRoundingMode("nearest"); err == nil {
+		t.Error("ParseRoundingMode(\"nearest\") error = nil, want an error")
+	}
+}
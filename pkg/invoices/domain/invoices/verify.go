@@ -0,0 +1,48 @@
+package invoices
+
+import "errors"
+
+// ErrInvalidComparison is returned when comparing totals of strategies that
+// don't compute them where the comparison expects
+var ErrInvalidComparison = errors.New("invalid totals comparison")
+
+// TotalPair joins the row of a strategy computing totals in the database
+// with the row of the same ID of a strategy computing them in Go.
+// Database and Application are nil when their strategy has no row with the ID
+type TotalPair struct {
+	Database    *Invoice
+	Application *Invoice
+}
+
+// Matched reports whether both rows exist and have the same inputs to the
+// total, so their totals must be equal
+func (p TotalPair) Matched() bool {
+	return p.Database != nil && p.Application != nil &&
+		p.Database.AmountCents() == p.Application.AmountCents() &&
+		p.Database.TaxRate() == p.Application.TaxRate()
+}
+
+// Mismatch returns the mismatch between the pair's totals, if the rows are
+// matched and their totals differ
+func (p TotalPair) Mismatch() (TotalMismatch, bool) {
+	if !p.Matched() || p.Database.TotalCents() == p.Application.TotalCents() {
+		return TotalMismatch{}, false
+	}
+	return TotalMismatch{
+		ID:                    p.Database.ID(),
+		AmountCents:           p.Database.AmountCents(),
+		TaxRate:               p.Database.TaxRate(),
+		DatabaseTotalCents:    p.Database.TotalCents(),
+		ApplicationTotalCents: p.Application.TotalCents(),
+	}, true
+}
+
+// TotalMismatch is an invoice whose total computed by the database differs
+// from the total computed in Go for the same inputs
+type TotalMismatch struct {
+	ID                    ID
+	AmountCents           int64
+	TaxRate               TaxRate
+	DatabaseTotalCents    int64
+	ApplicationTotalCents int64
+}
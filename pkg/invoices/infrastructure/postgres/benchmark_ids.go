@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// benchmarkIDSchema creates the table recording the ID ranges reserved by
+// write benchmarks. A benchmark writes its rows to one table at a time, so
+// while a range is reserved the tables may legitimately differ in it
+const benchmarkIDSchema = `
+	CREATE TABLE IF NOT EXISTS benchmark_id_ranges (
+		first_id    BIGINT PRIMARY KEY,
+		last_id     BIGINT NOT NULL,
+		reserved_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+`
+
+// outsideBenchmarkIDs returns a condition that id, an SQL expression, lies
+// outside every reserved benchmark range. Statements comparing tables add it
+// to ignore benchmark rows
+func outsideBenchmarkIDs(id string) string {
+	return "NOT EXISTS (SELECT 1 FROM benchmark_id_ranges b WHERE " + id + " BETWEEN b.first_id AND b.last_id)"
+}
+
+// ReserveBenchmarkIDs takes count consecutive IDs from the sequence of the
+// primary table, like Save, and records the range in the same transaction
+func (r *Repository) ReserveBenchmarkIDs(ctx context.Context, count int) (invoices.ID, error) {
+	strategies, err := r.writableStrategies()
+	if err != nil {
+		return 0, err
+	}
+	tables := make([]string, len(strategies))
+	for i, strategy := range strategies {
+		tables[i] = strategy.Table
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	first, err := reserveIDs(ctx, tx, tables, int64(count))
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO benchmark_id_ranges (first_id, last_id) VALUES ($1, $2)", first, first+int64(count)-1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record benchmark ids: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return invoices.ID(first), nil
+}
+
+// ReleaseBenchmarkIDs removes the range reserved from first on
+func (r *Repository) ReleaseBenchmarkIDs(ctx context.Context, first invoices.ID) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM benchmark_id_ranges WHERE first_id = $1", int64(first)); err != nil {
+		return fmt.Errorf("failed to release benchmark ids: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// writableStrategies returns the strategies owning a writable table, in
+// registration order. The first one is the primary, whose sequence assigns
+// invoice IDs and whose returned row is reported back
+func (r *Repository) writableStrategies() ([]Strategy, error) {
+	var strategies []Strategy
+	for _, strategy := range r.registry.All() {
+		if strategy.Writable {
+			strategies = append(strategies, strategy)
+		}
+	}
+	if len(strategies) == 0 {
+		return nil, fmt.Errorf("no writable strategies registered")
+	}
+	return strategies, nil
+}
+
+// returningColumns returns the RETURNING list matching the strategy's ScanRow
+func (s Strategy) returningColumns() string {
+	if s.HasTotalColumn {
+		return "id, customer_id, amount_cents, tax_rate, total_cents"
+	}
+	return "id, customer_id, amount_cents, tax_rate"
+}
+
+// checkTotal verifies that two tables computing the total in the database
+// agree on a written invoice. Totals computed in Go are not compared here
+func checkTotal(primary Strategy, inv *invoices.Invoice, other Strategy, written *invoices.Invoice) error {
+	if !primary.HasTotalColumn || !other.HasTotalColumn {
+		return nil
+	}
+	if inv.TotalCents() != written.TotalCents() {
+		return fmt.Errorf("total_cents of invoice %d diverges: %s computed %d, %s computed %d",
+			inv.ID(), primary.Table, inv.TotalCents(), other.Table, written.TotalCents())
+	}
+	return nil
+}
+
+// execer runs statements on a database or within a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// advanceSequence moves the id sequence of table to at least id, so inserts
+// relying on the column default don't collide with rows inserted with
+// explicit IDs
+func advanceSequence(ctx context.Context, db execer, table string, id int64) error {
+	_, err := db.ExecContext(ctx, `
+		SELECT setval(seq, $2)
+		FROM (SELECT pg_get_serial_sequence($1, 'id')::regclass AS seq) s
+		WHERE COALESCE(pg_sequence_last_value(seq), 0) < $2
+	`, table, id)
+	if err != nil {
+		return fmt.Errorf("failed to advance id sequence of %s: %w", table, err)
+	}
+	return nil
+}
+
+// reserveIDs takes count consecutive IDs from the id sequence of the first of
+// tables, which assigns invoice IDs, and returns the first. The range starts
+// after the highest ID of any table, so it doesn't collide with rows written
+// with explicit IDs either.
+//
+// The first table is locked against writes until tx ends. Inserts draw IDs
+// from the sequence, and could otherwise take one between reading and
+// setting it
+func reserveIDs(ctx context.Context, tx *sql.Tx, tables []string, count int64) (int64, error) {
+	primary := tables[0]
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE "+primary+" IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return 0, fmt.Errorf("failed to lock %s: %w", primary, err)
+	}
+
+	var maxID int64
+	for _, table := range tables {
+		var tableMax int64
+		if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM "+table).Scan(&tableMax); err != nil {
+			return 0, fmt.Errorf("failed to check existing ids of %s: %w", table, err)
+		}
+		maxID = max(maxID, tableMax)
+	}
+
+	var first int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT setval(seq, GREATEST(nextval(seq), $2 + 1) + $3 - 1) - $3 + 1
+		FROM (SELECT pg_get_serial_sequence($1, 'id')::regclass AS seq) s
+	`, primary, maxID, count).Scan(&first)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve ids from the sequence of %s: %w", primary, err)
+	}
+	return first, nil
+}
+
+// optionalTaxRate returns rate as a statement argument, which is NULL if
+// rate is nil. Rates are sent as text so NUMERIC columns receive them exactly
+func optionalTaxRate(rate *invoices.TaxRate) any {
+	if rate == nil {
+		return nil
+	}
+	return rate.String()
+}
+
+// queryInvoice runs a statement returning at most one row in the shape of
+// the strategy's fetch query, and maps it with ScanRow. It returns nil if
+// the statement returned no row
+func queryInvoice(ctx context.Context, tx *sql.Tx, strategy Strategy, query string, args ...any) (*invoices.Invoice, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	inv, err := strategy.ScanRow(rows)
+	if err != nil {
+		return nil, err
+	}
+	return inv, rows.Close()
+}
+
+// Save inserts the invoice into the primary table, then into every other
+// writable table under the ID the primary assigned. Tables computing the
+// total in the database must agree on it, or the insert is rolled back
+func (r *Repository) Save(ctx context.Context, input invoices.InvoiceInput) (*invoices.Invoice, error) {
+	strategies, err := r.writableStrategies()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	primary := strategies[0]
+	inv, err := queryInvoice(ctx, tx, primary, `
+		INSERT INTO `+primary.Table+` (customer_id, amount_cents, tax_rate)
+		VALUES ($1, $2, $3)
+		RETURNING `+primary.returningColumns(),
+		input.CustomerID, input.AmountCents, input.TaxRate.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert into %s: %w", primary.Table, err)
+	}
+
+	for _, strategy := range strategies[1:] {
+		written, err := queryInvoice(ctx, tx, strategy, `
+			INSERT INTO `+strategy.Table+` (id, customer_id, amount_cents, tax_rate)
+			VALUES ($1, $2, $3, $4)
+			RETURNING `+strategy.returningColumns(),
+			int64(inv.ID()), input.CustomerID, input.AmountCents, input.TaxRate.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert into %s: %w", strategy.Table, err)
+		}
+		if err := checkTotal(primary, inv, strategy, written); err != nil {
+			return nil, err
+		}
+
+		if err := advanceSequence(ctx, tx, strategy.Table, int64(inv.ID())); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return inv, nil
+}
+
+// outOfRange wraps err with invoices.ErrInvalidInvoice if the database
+// rejected a value as out of range. A partial update can combine a new amount
+// or tax rate with the stored one into a total that does not fit in BIGINT
+func outOfRange(err error) error {
+	var pqErr *pq.Error
+	// numeric_value_out_of_range
+	if errors.As(err, &pqErr) && pqErr.Code == "22003" {
+		return fmt.Errorf("%w: %w", invoices.ErrInvalidInvoice, err)
+	}
+	return err
+}
+
+// Update applies changes to the invoice in every writable table. Every table
+// must hold the invoice and agree on the new total, or the update is rolled back
+func (r *Repository) Update(ctx context.Context, id invoices.ID, changes invoices.InvoiceChanges) (*invoices.Invoice, error) {
+	strategies, err := r.writableStrategies()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var updated *invoices.Invoice
+	for i, strategy := range strategies {
+		inv, err := queryInvoice(ctx, tx, strategy, `
+			UPDATE `+strategy.Table+`
+			SET customer_id = COALESCE($2, customer_id),
+				amount_cents = COALESCE($3, amount_cents),
+				tax_rate = COALESCE($4, tax_rate)
+			WHERE id = $1
+			RETURNING `+strategy.returningColumns(),
+			int64(id), changes.CustomerID, changes.AmountCents, optionalTaxRate(changes.TaxRate))
+		if err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", strategy.Table, outOfRange(err))
+		}
+		if inv == nil {
+			if i == 0 {
+				return nil, fmt.Errorf("%w: %d", invoices.ErrInvoiceNotFound, id)
+			}
+			return nil, fmt.Errorf("invoice %d is missing from %s", id, strategy.Table)
+		}
+		if i == 0 {
+			updated = inv
+		} else if err := checkTotal(strategies[0], updated, strategy, inv); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return updated, nil
+}
+
+// Delete removes the invoice from every writable table. Every table must
+// hold the invoice, or the deletion is rolled back
+func (r *Repository) Delete(ctx context.Context, id invoices.ID) error {
+	strategies, err := r.writableStrategies()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, strategy := range strategies {
+		result, err := tx.ExecContext(ctx, "DELETE FROM "+strategy.Table+" WHERE id = $1", int64(id))
+		if err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", strategy.Table, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			if i == 0 {
+				return fmt.Errorf("%w: %d", invoices.ErrInvoiceNotFound, id)
+			}
+			return fmt.Errorf("invoice %d is missing from %s", id, strategy.Table)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -2,12 +2,13 @@ package postgres
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // Config holds database connection configuration
@@ -61,16 +62,23 @@ func (c Config) Validate() error {
 	return nil
 }
 
-// NewConnection creates a new database connection
-func NewConnection(cfg Config) (*sql.DB, error) {
+// NewConnection creates a new database connection. If network is not nil,
+// every connection is opened through it to count its bytes on the wire
+func NewConnection(cfg Config, network *NetworkCounter) (*sql.DB, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open("postgres", cfg.ConnectionString())
+	dsn := cfg.ConnectionString()
+	var connector driver.Connector
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	if network != nil {
+		connector = network.connector(dsn)
+	}
+	db := sql.OpenDB(connector)
 
 	if err = db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
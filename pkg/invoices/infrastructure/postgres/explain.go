@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// explainOutput mirrors the document produced by EXPLAIN (FORMAT JSON)
+type explainOutput struct {
+	Plan struct {
+		SharedHitBlocks  int64 `json:"Shared Hit Blocks"`
+		SharedReadBlocks int64 `json:"Shared Read Blocks"`
+	} `json:"Plan"`
+	PlanningTime  float64 `json:"Planning Time"`
+	ExecutionTime float64 `json:"Execution Time"`
+}
+
+// Explain runs the statement Stream sends for q under
+// EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON)
+func (r *Repository) Explain(ctx context.Context, name string, q invoices.Query) (invoices.QueryPlan, error) {
+	strategy, err := r.registry.Get(name)
+	if err != nil {
+		return invoices.QueryPlan{}, err
+	}
+
+	query, args := strategy.taggedFetchQuery(q)
+	var raw []byte
+	err = r.db.QueryRowContext(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+query, args...).Scan(&raw)
+	if err != nil {
+		return invoices.QueryPlan{}, err
+	}
+
+	var outputs []explainOutput
+	if err := json.Unmarshal(raw, &outputs); err != nil {
+		return invoices.QueryPlan{}, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(outputs) == 0 {
+		return invoices.QueryPlan{}, fmt.Errorf("failed to parse plan: empty output")
+	}
+
+	// The root node's buffer counts include all of its children
+	out := outputs[0]
+	return invoices.QueryPlan{
+		PlanningTime:     time.Duration(out.PlanningTime * float64(time.Millisecond)),
+		ExecutionTime:    time.Duration(out.ExecutionTime * float64(time.Millisecond)),
+		SharedHitBlocks:  out.Plan.SharedHitBlocks,
+		SharedReadBlocks: out.Plan.SharedReadBlocks,
+		Plan:             raw,
+	}, nil
+}
+
+// ExplainPage runs the statement FindPage sends for q, including the row
+// probing for a next page, under EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON)
+func (r *Repository) ExplainPage(ctx context.Context, name string, q invoices.Query) (invoices.QueryPlan, error) {
+	return r.Explain(ctx, name, pageProbe(q))
+}
@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// NetworkCounter counts the bytes exchanged with the database over each
+// connection it opens, so a fetch can be charged for its own connection's
+// traffic rather than the whole pool's
+type NetworkCounter struct {
+	mu    sync.Mutex
+	conns map[driver.Conn]*countingConn
+}
+
+// NewNetworkCounter creates a NetworkCounter
+func NewNetworkCounter() *NetworkCounter {
+	return &NetworkCounter{conns: make(map[driver.Conn]*countingConn)}
+}
+
+// connector returns a connector opening pq connections to dsn, each dialed
+// through its own counting conn
+func (c *NetworkCounter) connector(dsn string) driver.Connector {
+	return &countingConnector{dsn: dsn, counter: c}
+}
+
+// ConnCounters returns the bytes exchanged so far over the connection
+// checked out as conn, or zero if it was not opened through c
+func (c *NetworkCounter) ConnCounters(conn *sql.Conn) (invoices.NetworkCounters, error) {
+	var counters invoices.NetworkCounters
+	err := conn.Raw(func(driverConn any) error {
+		key, _ := driverConn.(driver.Conn)
+		c.mu.Lock()
+		cc := c.conns[key]
+		c.mu.Unlock()
+		if cc != nil {
+			counters = cc.counters()
+		}
+		return nil
+	})
+	return counters, err
+}
+
+func (c *NetworkCounter) track(driverConn driver.Conn, cc *countingConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cc.driverConn = driverConn
+	c.conns[driverConn] = cc
+}
+
+func (c *NetworkCounter) untrack(cc *countingConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cc.driverConn != nil {
+		delete(c.conns, cc.driverConn)
+	}
+}
+
+// countingConnector opens pq connections and records which counting conn
+// each one reads and writes through
+type countingConnector struct {
+	dsn     string
+	counter *NetworkCounter
+}
+
+func (c *countingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	// A connector per connection, so its dialer knows which conn it opened
+	connector, err := pq.NewConnector(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &connDialer{counter: c.counter}
+	connector.Dialer(dialer)
+
+	driverConn, err := connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cc := dialer.connected(); cc != nil {
+		c.counter.track(driverConn, cc)
+	}
+	return driverConn, nil
+}
+
+func (c *countingConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// connDialer is the pq dialer of a single connection. Dials after the
+// connection is established, such as cancel requests, are not counted
+type connDialer struct {
+	dialer  net.Dialer
+	counter *NetworkCounter
+
+	mu   sync.Mutex
+	conn *countingConn
+	done bool
+}
+
+// Dial implements pq.Dialer
+func (d *connDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialTimeout implements pq.Dialer
+func (d *connDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+// DialContext implements pq.DialerContext
+func (d *connDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.done {
+		return conn, nil
+	}
+	// pq may dial again while connecting, for example to retry without
+	// SSL, so the last conn dialed is the one the connection uses
+	d.conn = &countingConn{Conn: conn, counter: d.counter}
+	return d.conn, nil
+}
+
+// connected ends counting new dials and returns the conn the connection uses
+func (d *connDialer) connected() *countingConn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.done = true
+	return d.conn
+}
+
+// countingConn counts the bytes read from and written to a connection
+type countingConn struct {
+	net.Conn
+	counter    *NetworkCounter
+	driverConn driver.Conn
+	received   atomic.Int64
+	sent       atomic.Int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.received.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.sent.Add(int64(n))
+	return n, err
+}
+
+// Close stops tracking the connection, so the counter does not keep closed
+// connections alive
+func (c *countingConn) Close() error {
+	c.counter.untrack(c)
+	return c.Conn.Close()
+}
+
+func (c *countingConn) counters() invoices.NetworkCounters {
+	return invoices.NetworkCounters{
+		Received: c.received.Load(),
+		Sent:     c.sent.Load(),
+	}
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// buildFetchQuery appends the filters, ordering and paging of q to a
+// strategy's fetch query and returns the statement with its arguments
+func buildFetchQuery(fetchQuery string, q invoices.Query) (string, []any) {
+	var conditions []string
+	var args []any
+	bind := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+
+	if q.AfterID > 0 {
+		bind("id >", int64(q.AfterID))
+	}
+	if q.CustomerID != nil {
+		bind("customer_id =", *q.CustomerID)
+	}
+	if q.MinAmountCents != nil {
+		bind("amount_cents >=", *q.MinAmountCents)
+	}
+	if q.MaxAmountCents != nil {
+		bind("amount_cents <=", *q.MaxAmountCents)
+	}
+	if q.MinTaxRate != nil {
+		bind("tax_rate >=", q.MinTaxRate.String())
+	}
+	if q.MaxTaxRate != nil {
+		bind("tax_rate <=", q.MaxTaxRate.String())
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(fetchQuery))
+	if len(conditions) > 0 {
+		b.WriteString("\nWHERE ")
+		b.WriteString(strings.Join(conditions, " AND "))
+	}
+
+	args = append(args, q.Limit)
+	b.WriteString("\nORDER BY id\nLIMIT $" + strconv.Itoa(len(args)))
+	if q.Offset > 0 {
+		args = append(args, q.Offset)
+		b.WriteString(" OFFSET $" + strconv.Itoa(len(args)))
+	}
+
+	return b.String(), args
+}
@@ -3,111 +3,293 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
 )
 
 // Repository implements invoices.Repository using PostgreSQL
 type Repository struct {
-	db *sql.DB
+	db       *sql.DB
+	registry *Registry
+	network  *NetworkCounter
 }
 
-// NewRepository creates a new PostgreSQL repository
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+// NewRepository creates a new PostgreSQL repository serving the strategies in
+// registry. network is the counter db was opened with, and may be nil, in
+// which case fetches add nothing to the network usage of their context
+func NewRepository(db *sql.DB, registry *Registry, network *NetworkCounter) *Repository {
+	return &Repository{db: db, registry: registry, network: network}
 }
 
-// FindAllWithVirtual returns invoices with pre-computed total from DB
-func (r *Repository) FindAllWithVirtual(ctx context.Context, limit int) ([]*invoices.Invoice, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, customer_id, amount_cents, tax_rate, total_cents
-		FROM invoices_with_virtual
-		ORDER BY id
-		LIMIT $1
-	`, limit)
+// withConn runs fn on a connection checked out from the pool for the call.
+// When ctx carries a network usage, the bytes exchanged over that connection
+// while fn runs are added to it
+func (r *Repository) withConn(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer conn.Close()
 
-	result := make([]*invoices.Invoice, 0, limit)
-	for rows.Next() {
-		var id int64
-		var customerID int64
-		var amountCents int64
-		var taxRate float64
-		var totalCents int64
+	usage := invoices.NetworkUsage(ctx)
+	if usage == nil || r.network == nil {
+		return fn(conn)
+	}
 
-		if err := rows.Scan(&id, &customerID, &amountCents, &taxRate, &totalCents); err != nil {
-			return nil, err
-		}
+	start, err := r.network.ConnCounters(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read network counters: %w", err)
+	}
+	if err := fn(conn); err != nil {
+		return err
+	}
+	end, err := r.network.ConnCounters(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read network counters: %w", err)
+	}
+	*usage = usage.Add(end.Sub(start))
+	return nil
+}
 
-		result = append(result, invoices.NewInvoice(
-			invoices.ID(id),
-			customerID,
-			amountCents,
-			taxRate,
-			totalCents,
-		))
+// Strategies returns the registered strategies in registration order
+func (r *Repository) Strategies() []invoices.Strategy {
+	all := r.registry.All()
+	result := make([]invoices.Strategy, len(all))
+	for i, s := range all {
+		result[i] = s.Strategy
 	}
+	return result
+}
 
-	if err := rows.Err(); err != nil {
+// FindAll returns the invoices selected by q, fetched using the named strategy
+func (r *Repository) FindAll(ctx context.Context, name string, q invoices.Query) ([]*invoices.Invoice, error) {
+	result := make([]*invoices.Invoice, 0, q.Limit)
+	err := r.Stream(ctx, name, q, func(inv *invoices.Invoice) error {
+		result = append(result, inv)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-
 	return result, nil
 }
 
-// FindAllWithoutVirtual returns invoices without pre-computed total
-func (r *Repository) FindAllWithoutVirtual(ctx context.Context, limit int) ([]*invoices.Invoice, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, customer_id, amount_cents, tax_rate
-		FROM invoices_without_virtual
-		ORDER BY id
-		LIMIT $1
-	`, limit)
+// Stream fetches the invoices selected by q using the named strategy and
+// calls fn with each row as it is read, without buffering the result set.
+// An error from fn stops the stream and is returned
+func (r *Repository) Stream(ctx context.Context, name string, q invoices.Query, fn func(*invoices.Invoice) error) error {
+	strategy, err := r.registry.Get(name)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	result := make([]*invoices.Invoice, 0, limit)
-	for rows.Next() {
-		var id int64
-		var customerID int64
-		var amountCents int64
-		var taxRate float64
+	query, args := strategy.taggedFetchQuery(q)
+	return r.withConn(ctx, func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
 
-		if err := rows.Scan(&id, &customerID, &amountCents, &taxRate); err != nil {
-			return nil, err
+		for rows.Next() {
+			inv, err := strategy.ScanRow(rows)
+			if err != nil {
+				return err
+			}
+			if err := fn(inv); err != nil {
+				return err
+			}
 		}
 
-		// Calculate total in Go
-		result = append(result, invoices.NewInvoiceWithCalculation(
-			invoices.ID(id),
-			customerID,
-			amountCents,
-			taxRate,
-		))
+		return rows.Err()
+	})
+}
+
+// FindPage returns a page of the invoices selected by q, fetched using the
+// named strategy. One extra row is fetched to tell whether another page follows
+func (r *Repository) FindPage(ctx context.Context, name string, q invoices.Query) (invoices.Page, error) {
+	invs, err := r.FindAll(ctx, name, pageProbe(q))
+	if err != nil {
+		return invoices.Page{}, err
 	}
 
-	if err := rows.Err(); err != nil {
+	if len(invs) <= q.Limit {
+		return invoices.Page{Invoices: invs}, nil
+	}
+	invs = invs[:q.Limit]
+	return invoices.Page{Invoices: invs, NextAfterID: invs[len(invs)-1].ID()}, nil
+}
+
+// pageProbe returns q fetching one row more than a page of q
+func pageProbe(q invoices.Query) invoices.Query {
+	q.Limit++
+	return q
+}
+
+// FindByID returns the invoice with the given id, fetched using the named strategy
+func (r *Repository) FindByID(ctx context.Context, name string, id invoices.ID) (*invoices.Invoice, error) {
+	strategy, err := r.registry.Get(name)
+	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	var inv *invoices.Invoice
+	err = r.withConn(ctx, func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(ctx, strategy.taggedLookupQuery(), int64(id))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %d", invoices.ErrInvoiceNotFound, id)
+		}
+		inv, err = strategy.ScanRow(rows)
+		return err
+	})
+	return inv, err
 }
 
-// CountWithVirtual returns the count of invoices in the virtual table
-func (r *Repository) CountWithVirtual(ctx context.Context) (int64, error) {
+// Count returns the count of invoices in the named strategy's table
+func (r *Repository) Count(ctx context.Context, name string) (int64, error) {
+	strategy, err := r.registry.Get(name)
+	if err != nil {
+		return 0, err
+	}
+
 	var count int64
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM invoices_with_virtual").Scan(&count)
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+strategy.Table).Scan(&count)
 	return count, err
 }
 
-// CountWithoutVirtual returns the count of invoices in the non-virtual table
-func (r *Repository) CountWithoutVirtual(ctx context.Context) (int64, error) {
-	var count int64
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM invoices_without_virtual").Scan(&count)
-	return count, err
+// Refresh runs the named strategy's refresh statement
+func (r *Repository) Refresh(ctx context.Context, name string) error {
+	strategy, err := r.registry.Get(name)
+	if err != nil {
+		return err
+	}
+	if !strategy.Refreshable {
+		return fmt.Errorf("%w: %s", invoices.ErrStrategyNotRefreshable, name)
+	}
+
+	_, err = r.db.ExecContext(ctx, strategy.RefreshQuery)
+	return err
+}
+
+// writableStrategy returns the named strategy if it accepts writes
+func (r *Repository) writableStrategy(name string) (Strategy, error) {
+	strategy, err := r.registry.Get(name)
+	if err != nil {
+		return Strategy{}, err
+	}
+	if !strategy.Writable {
+		return Strategy{}, fmt.Errorf("%w: %s", invoices.ErrStrategyNotWritable, name)
+	}
+	return strategy, nil
+}
+
+// InsertRow writes a single invoice with the given ID to the named
+// strategy's table
+func (r *Repository) InsertRow(ctx context.Context, name string, id invoices.ID, customerID, amountCents int64, taxRate invoices.TaxRate) error {
+	strategy, err := r.writableStrategy(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO `+strategy.Table+` (id, customer_id, amount_cents, tax_rate)
+		VALUES ($1, $2, $3, $4)
+	`, int64(id), customerID, amountCents, taxRate.String())
+	return err
+}
+
+// UpdateRow sets amount_cents and tax_rate of a single invoice in the named strategy's table
+func (r *Repository) UpdateRow(ctx context.Context, name string, id invoices.ID, amountCents int64, taxRate invoices.TaxRate) error {
+	strategy, err := r.writableStrategy(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE `+strategy.Table+`
+		SET amount_cents = $2, tax_rate = $3
+		WHERE id = $1
+	`, int64(id), amountCents, taxRate.String())
+	return err
+}
+
+// DeleteRow removes a single invoice from the named strategy's table
+func (r *Repository) DeleteRow(ctx context.Context, name string, id invoices.ID) error {
+	strategy, err := r.writableStrategy(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, "DELETE FROM "+strategy.Table+" WHERE id = $1", int64(id))
+	return err
+}
+
+// StatementStats returns cumulative pg_stat_statements counters for the named
+// strategy's fetch query, matched by its fetch statement tag
+func (r *Repository) StatementStats(ctx context.Context, name string) (invoices.StatementStats, error) {
+	if _, err := r.registry.Get(name); err != nil {
+		return invoices.StatementStats{}, err
+	}
+
+	var calls int64
+	var execMs, ioMs float64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(calls), 0)::BIGINT,
+			COALESCE(SUM(total_exec_time), 0)::FLOAT8,
+			COALESCE(SUM(
+				shared_blk_read_time + shared_blk_write_time +
+				local_blk_read_time + local_blk_write_time +
+				temp_blk_read_time + temp_blk_write_time
+			), 0)::FLOAT8
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+			AND starts_with(query, $1)
+	`, statementTag(name, statementFetch)).Scan(&calls, &execMs, &ioMs)
+	if err != nil {
+		var pqErr *pq.Error
+		// undefined_table: extension not installed,
+		// object_not_in_prerequisite_state: library not preloaded
+		if errors.As(err, &pqErr) && (pqErr.Code == "42P01" || pqErr.Code == "55000") {
+			return invoices.StatementStats{}, fmt.Errorf("%w: %s", invoices.ErrStatementStatsUnavailable, pqErr.Message)
+		}
+		return invoices.StatementStats{}, err
+	}
+
+	return invoices.StatementStats{
+		Calls:    calls,
+		ExecTime: time.Duration(execMs * float64(time.Millisecond)),
+		IOTime:   time.Duration(ioMs * float64(time.Millisecond)),
+	}, nil
+}
+
+// StorageSnapshot returns the size of the named strategy's table and its
+// indexes, together with the current WAL position in bytes
+func (r *Repository) StorageSnapshot(ctx context.Context, name string) (invoices.StorageSnapshot, error) {
+	strategy, err := r.registry.Get(name)
+	if err != nil {
+		return invoices.StorageSnapshot{}, err
+	}
+
+	var snapshot invoices.StorageSnapshot
+	err = r.db.QueryRowContext(ctx, `
+		SELECT
+			pg_table_size($1::regclass),
+			pg_indexes_size($1::regclass),
+			pg_wal_lsn_diff(pg_current_wal_lsn(), '0/0')::BIGINT
+	`, strategy.Table).Scan(&snapshot.TableBytes, &snapshot.IndexBytes, &snapshot.WALPosition)
+	return snapshot, err
 }
@@ -0,0 +1,592 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SeedMode selects how Seed writes rows
+type SeedMode string
+
+const (
+	// SeedModeCopy generates rows in Go and streams them with COPY FROM STDIN
+	SeedModeCopy SeedMode = "copy"
+
+	// SeedModeGenerate generates rows in the database with INSERT ... SELECT
+	// FROM generate_series, so they never cross the wire
+	SeedModeGenerate SeedMode = "generate"
+
+	// SeedModeInsert inserts rows one prepared statement execution at a time
+	SeedModeInsert SeedMode = "insert"
+)
+
+// seedBatch writes the count rows starting at ID first to every table in
+// tx, generating them with gen
+type seedBatch func(ctx context.Context, tx *sql.Tx, tables []string, gen *rowGenerator, first int64, count int) error
+
+// seedModes maps each mode to its batch writer and default batch size
+var seedModes = map[SeedMode]struct {
+	batch     seedBatch
+	batchSize int
+}{
+	SeedModeCopy:     {batch: copyBatch, batchSize: 100000},
+	SeedModeGenerate: {batch: generateBatch, batchSize: 100000},
+	SeedModeInsert:   {batch: insertBatch, batchSize: 5000},
+}
+
+// DefaultRandomSeed is the random seed used without SEED_RANDOM_SEED, so
+// environments seeded with the defaults hold identical data
+const DefaultRandomSeed int64 = 42
+
+// SeedConfig holds seeding configuration
+type SeedConfig struct {
+	// Count is the number of rows every seeded table should hold
+	Count int
+
+	// Workers is the number of batches written concurrently
+	Workers int
+
+	// BatchSize is the number of rows written per transaction. Zero uses the
+	// mode's default
+	BatchSize int
+
+	Mode SeedMode
+
+	// RandomSeed determines every generated row. Seeding with the same seed
+	// and mode produces identical data, whatever the workers and batch size.
+	// copy and insert produce the same rows, generate other ones, so Seed
+	// refuses to mix generate with the other modes in one database
+	RandomSeed int64
+
+	// Profile describes the distributions rows are drawn from
+	Profile SeedProfile
+
+	// RepairDrift compares every seeded table whose row count, highest ID or
+	// row checksum differ from the first's with it before seeding, and makes
+	// their rows identical. Summarizing scans every table in full, so it is
+	// off unless requested
+	RepairDrift bool
+}
+
+// SeedConfigFromEnv creates SeedConfig from SEED_COUNT, SEED_WORKERS,
+// SEED_BATCH_SIZE, SEED_MODE, SEED_RANDOM_SEED and SEED_REPAIR_DRIFT. Without
+// SEED_RANDOM_SEED the seed is DefaultRandomSeed, and drift is only repaired
+// with SEED_REPAIR_DRIFT=true. The profile is left empty, to be loaded with
+// SeedProfileFromEnv or LoadSeedProfile
+func SeedConfigFromEnv() (SeedConfig, error) {
+	cfg := SeedConfig{
+		Count:      1000000000, // 1 billion default
+		Workers:    10,
+		Mode:       SeedMode(os.Getenv("SEED_MODE")),
+		RandomSeed: DefaultRandomSeed,
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("SEED_COUNT")); err == nil {
+		cfg.Count = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("SEED_WORKERS")); err == nil {
+		cfg.Workers = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("SEED_BATCH_SIZE")); err == nil {
+		cfg.BatchSize = n
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = SeedModeCopy
+	}
+	if v := os.Getenv("SEED_RANDOM_SEED"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return SeedConfig{}, fmt.Errorf("SEED_RANDOM_SEED must be an integer: %w", err)
+		}
+		cfg.RandomSeed = seed
+	}
+	if v := os.Getenv("SEED_REPAIR_DRIFT"); v != "" {
+		repair, err := strconv.ParseBool(v)
+		if err != nil {
+			return SeedConfig{}, fmt.Errorf("SEED_REPAIR_DRIFT must be a boolean: %w", err)
+		}
+		cfg.RepairDrift = repair
+	}
+
+	return cfg, nil
+}
+
+// SeedProfileFromEnv loads the profile named by SEED_PROFILE, or the default
+// profile without it
+func SeedProfileFromEnv() (SeedProfile, error) {
+	profile := os.Getenv("SEED_PROFILE")
+	if profile == "" {
+		profile = DefaultSeedProfile
+	}
+	return LoadSeedProfile(profile)
+}
+
+// Validate checks the mode, the profile and that workers and batches are
+// positive
+func (c SeedConfig) Validate() error {
+	if _, ok := seedModes[c.Mode]; !ok {
+		return fmt.Errorf("unknown seed mode %q, expected %s, %s or %s", c.Mode, SeedModeCopy, SeedModeGenerate, SeedModeInsert)
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("seed workers must be positive")
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("seed batch size must not be negative")
+	}
+	if err := c.Profile.Validate(); err != nil {
+		return err
+	}
+	if c.Mode == SeedModeGenerate && !c.Profile.sqlExpressible() {
+		return fmt.Errorf("seed profile %s: %s mode supports only uniform distributions without edge values", c.Profile.Name, SeedModeGenerate)
+	}
+	return nil
+}
+
+// sameRowModes returns the modes deriving the same rows from a random seed
+// as mode. generate derives them in SQL, the other modes with rowGenerator
+func sameRowModes(mode SeedMode) []SeedMode {
+	if mode == SeedModeGenerate {
+		return []SeedMode{SeedModeGenerate}
+	}
+	return []SeedMode{SeedModeCopy, SeedModeInsert}
+}
+
+// seededTables returns the tables Seed writes to, in registration order
+func seededTables(registry *Registry) []string {
+	var tables []string
+	for _, strategy := range registry.All() {
+		if strategy.Writable {
+			tables = append(tables, strategy.Table)
+		}
+	}
+	return tables
+}
+
+// Seed populates the database with random data using concurrent workers,
+// until every seeded table holds cfg.Count rows. Rows get explicit IDs
+// following the highest existing one, and the same ID holds the same values
+// in every table, derived from the random seed and the ID alone.
+//
+// Seeding plans a run of IDs and records every batch in the transaction
+// writing it. Canceling ctx rolls back the batches in progress, and seeding
+// again first resumes the unfinished run with its original settings, writing
+// exactly the batches that are missing. Seeding fails if the database holds
+// runs whose mode derives other rows than cfg.Mode
+func Seed(ctx context.Context, db *sql.DB, registry *Registry, cfg SeedConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	tables := seededTables(registry)
+	if len(tables) == 0 {
+		log.Println("No seeded strategies registered, skipping...")
+		return nil
+	}
+
+	unlock, err := lockSeeding(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Resuming or extending runs of a mode deriving other rows would leave
+	// data no single mode reproduces
+	if other, found, err := otherSeedMode(ctx, db, sameRowModes(cfg.Mode)); err != nil {
+		return err
+	} else if found {
+		return fmt.Errorf("database was seeded in %s mode, which derives other rows than %s mode; seed it in %s mode or reset it",
+			other, cfg.Mode, other)
+	}
+
+	changed := false
+	if cfg.RepairDrift {
+		if changed, err = repairDrift(ctx, db, tables); err != nil {
+			return err
+		}
+	}
+
+	run, found, err := incompleteSeedRun(ctx, db)
+	if err != nil {
+		return err
+	}
+	if found {
+		log.Printf("Resuming seed run %d of %d rows from id %d, using %s with profile %s and random seed %d...",
+			run.id, run.count, run.firstID, run.mode, run.profile.Name, run.randomSeed)
+		if err := writeSeedRun(ctx, db, tables, run, cfg.Workers); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	count, err := seededCount(ctx, db, tables, cfg.RepairDrift)
+	if err != nil {
+		return err
+	}
+
+	if count >= int64(cfg.Count) {
+		log.Printf("Data already seeded (%d rows), skipping...", count)
+	} else {
+		batchSize := cfg.BatchSize
+		if batchSize == 0 {
+			batchSize = seedModes[cfg.Mode].batchSize
+		}
+		run, err := startSeedRun(ctx, db, tables, seedRun{
+			count:      int64(cfg.Count) - count,
+			batchSize:  batchSize,
+			mode:       cfg.Mode,
+			randomSeed: cfg.RandomSeed,
+			profile:    cfg.Profile,
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Seeding %d rows as run %d, profile %s, random seed %d (existing: %d, target: %d)...",
+			run.count, run.id, run.profile.Name, run.randomSeed, count, cfg.Count)
+		if err := writeSeedRun(ctx, db, tables, run, cfg.Workers); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return refreshAll(ctx, db, registry)
+}
+
+// seededCount returns the number of rows in the seeded tables, outside
+// reserved benchmark IDs. Unless drift was just repaired, every table is
+// counted and must hold the same number
+func seededCount(ctx context.Context, db *sql.DB, tables []string, repaired bool) (int64, error) {
+	if repaired {
+		tables = tables[:1]
+	}
+
+	var count int64
+	for i, table := range tables {
+		var n int64
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table+" t WHERE "+outsideBenchmarkIDs("t.id")).Scan(&n); err != nil {
+			return 0, fmt.Errorf("failed to check existing data: %w", err)
+		}
+		if i > 0 && n != count {
+			return 0, fmt.Errorf("%s holds %d rows but %s holds %d, seed with drift repair to make them identical",
+				tables[0], count, table, n)
+		}
+		count = n
+	}
+	return count, nil
+}
+
+// writeSeedRun writes the batches of run that are not committed yet using
+// concurrent workers, and marks the run completed
+func writeSeedRun(ctx context.Context, db *sql.DB, tables []string, run seedRun, workers int) error {
+	committed, committedRows, err := committedSeedBatches(ctx, db, run)
+	if err != nil {
+		return err
+	}
+	remaining := run.count - committedRows
+	batch := seedModes[run.mode].batch
+
+	log.Printf("Writing %d rows with %d workers using %s in batches of %d (%d rows already committed)...",
+		remaining, workers, run.mode, run.batchSize, committedRows)
+	start := time.Now()
+
+	type job struct {
+		first int64
+		count int
+	}
+	jobs := make(chan job, workers*2)
+	results := make(chan error, workers)
+	var inserted int64
+
+	// Start workers
+	for w := 0; w < workers; w++ {
+		go func() {
+			gen := newRowGenerator(run.randomSeed, run.profile)
+			for j := range jobs {
+				if err := writeSeedBatch(ctx, db, tables, batch, gen, run, j.first, j.count); err != nil {
+					results <- err
+					return
+				}
+				atomic.AddInt64(&inserted, int64(j.count))
+			}
+			results <- nil
+		}()
+	}
+
+	// Progress reporter
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ins := atomic.LoadInt64(&inserted)
+				elapsed := time.Since(start)
+				rate := float64(ins) / elapsed.Seconds()
+				eta := time.Duration(float64(remaining-ins) / rate * float64(time.Second))
+				log.Printf("Inserted %d/%d rows (%.0f rows/sec, ETA: %v)", ins, remaining, rate, eta.Round(time.Second))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Send the uncommitted batches, until a worker fails or ctx is canceled
+	var firstErr error
+	failed := 0
+send:
+	for first := run.firstID; first <= run.lastID(); first += int64(run.batchSize) {
+		if committed[first] {
+			continue
+		}
+		j := job{first: first, count: int(min(int64(run.batchSize), run.lastID()-first+1))}
+		select {
+		case jobs <- j:
+		case err := <-results:
+			firstErr = err
+			failed++
+			break send
+		case <-ctx.Done():
+			firstErr = ctx.Err()
+			break send
+		}
+	}
+	close(jobs)
+
+	// Wait for workers
+	for w := failed; w < workers; w++ {
+		if err := <-results; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	close(done)
+
+	if firstErr != nil {
+		if ctx.Err() != nil {
+			log.Printf("Seeding stopped after %d rows, run it again to resume", atomic.LoadInt64(&inserted))
+		}
+		return firstErr
+	}
+
+	if err := completeSeedRun(ctx, db, run); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	log.Printf("Seeding completed: %d rows into %d tables in %v using %s (%.0f rows/sec, %.0f table rows/sec)",
+		inserted, len(tables), elapsed.Round(time.Millisecond), run.mode,
+		float64(inserted)/elapsed.Seconds(), float64(inserted)*float64(len(tables))/elapsed.Seconds())
+	return nil
+}
+
+// writeSeedBatch writes a batch of the run to every table and records it in
+// one transaction, so a batch is either complete and recorded or absent
+func writeSeedBatch(ctx context.Context, db *sql.DB, tables []string, batch seedBatch, gen *rowGenerator, run seedRun, first int64, count int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := batch(ctx, tx, tables, gen, first, count); err != nil {
+		return err
+	}
+	if err := recordSeedBatch(ctx, tx, run, first, count); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// refreshAll refreshes the precomputed data of every refreshable strategy
+func refreshAll(ctx context.Context, db *sql.DB, registry *Registry) error {
+	for _, strategy := range registry.All() {
+		if !strategy.Refreshable {
+			continue
+		}
+
+		start := time.Now()
+		if _, err := db.ExecContext(ctx, strategy.RefreshQuery); err != nil {
+			return fmt.Errorf("failed to refresh strategy %q: %w", strategy.Name, err)
+		}
+		log.Printf("Refreshed strategy %q in %v", strategy.Name, time.Since(start))
+	}
+	return nil
+}
+
+// seedRow holds the columns of a seeded invoice
+type seedRow struct {
+	id          int64
+	customerID  int64
+	amountCents int64
+	taxRate     string
+}
+
+// rowSource is a splitmix64 random source, reset before each row to a state
+// derived from the random seed and the row's ID. A row's values therefore
+// don't depend on which worker or batch generates it
+type rowSource struct {
+	state uint64
+}
+
+func (s *rowSource) reset(seed, id int64) {
+	s.state = uint64(seed)
+	s.state = s.Uint64() ^ uint64(id)
+}
+
+func (s *rowSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *rowSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// Seed implements rand.Source. Rows are seeded with reset instead
+func (s *rowSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// rowGenerator derives seeded rows from their ID. It is not safe for
+// concurrent use, so every worker has its own
+type rowGenerator struct {
+	seed    int64
+	profile SeedProfile
+	src     *rowSource
+	r       *rand.Rand
+	sampler *profileSampler
+}
+
+func newRowGenerator(seed int64, profile SeedProfile) *rowGenerator {
+	src := &rowSource{}
+	r := rand.New(src)
+	return &rowGenerator{seed: seed, profile: profile, src: src, r: r, sampler: newProfileSampler(profile, r)}
+}
+
+// row returns the row with the given ID
+func (g *rowGenerator) row(id int64) seedRow {
+	g.src.reset(g.seed, id)
+	customerID := g.sampler.customerID(g.r)
+	amountCents := g.sampler.amountCents(g.r)
+	taxRate := g.sampler.taxRate(g.r)
+	if edge, ok := g.sampler.edge(g.r); ok {
+		amountCents = edge.AmountCents
+		if edge.TaxRate != nil {
+			taxRate = *edge.TaxRate
+		}
+	}
+	return seedRow{id: id, customerID: customerID, amountCents: amountCents, taxRate: taxRate.String()}
+}
+
+func insertBatch(ctx context.Context, tx *sql.Tx, tables []string, gen *rowGenerator, first int64, count int) error {
+	stmts := make([]*sql.Stmt, len(tables))
+	for i, table := range tables {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO `+table+` (id, customer_id, amount_cents, tax_rate)
+			VALUES ($1, $2, $3, $4)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+		stmts[i] = stmt
+	}
+
+	for id := first; id < first+int64(count); id++ {
+		row := gen.row(id)
+		for j, stmt := range stmts {
+			if _, err := stmt.ExecContext(ctx, row.id, row.customerID, row.amountCents, row.taxRate); err != nil {
+				return fmt.Errorf("failed to insert into %s: %w", tables[j], err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyBatch generates the rows once and streams them into every table with
+// COPY FROM STDIN. The driver buffers rows and sends them in large messages,
+// so there is no round trip per row
+func copyBatch(ctx context.Context, tx *sql.Tx, tables []string, gen *rowGenerator, first int64, count int) error {
+	rows := make([]seedRow, count)
+	for i := range rows {
+		rows[i] = gen.row(first + int64(i))
+	}
+
+	for _, table := range tables {
+		stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, "id", "customer_id", "amount_cents", "tax_rate"))
+		if err != nil {
+			return fmt.Errorf("failed to start copy into %s: %w", table, err)
+		}
+		for _, row := range rows {
+			if _, err := stmt.ExecContext(ctx, row.id, row.customerID, row.amountCents, row.taxRate); err != nil {
+				stmt.Close()
+				return fmt.Errorf("failed to copy into %s: %w", table, err)
+			}
+		}
+		// An Exec without arguments flushes the buffered rows and ends the copy
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy into %s: %w", table, err)
+		}
+		if err := stmt.Close(); err != nil {
+			return fmt.Errorf("failed to copy into %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// generateBatch generates the rows with generate_series in a single
+// statement. Each column is derived from the ID with hashint8extended, keyed
+// by the random seed, so the database generates reproducible rows too,
+// though not the same ones as rowGenerator, within the uniform ranges of the
+// profile. Seed therefore never mixes it with the other modes.
+//
+// The materialized CTE is computed once and inserted into every table
+func generateBatch(ctx context.Context, tx *sql.Tx, tables []string, gen *rowGenerator, first int64, count int) error {
+	const columns = "id, customer_id, amount_cents, tax_rate"
+
+	var b strings.Builder
+	b.WriteString(`WITH seed AS MATERIALIZED (
+		SELECT
+			id,
+			(hashint8extended(id, $3::BIGINT) & 9223372036854775807) % $4::BIGINT + 1 AS customer_id,
+			(hashint8extended(id, $3::BIGINT # 1) & 9223372036854775807) % $6::BIGINT + $5::BIGINT AS amount_cents,
+			(((hashint8extended(id, $3::BIGINT # 2) & 9223372036854775807) % $8::BIGINT + $7::BIGINT)::NUMERIC / 100)::NUMERIC(4,2) AS tax_rate
+		FROM generate_series($1::BIGINT, $2::BIGINT) AS id
+	)`)
+	last := len(tables) - 1
+	for i, table := range tables[:last] {
+		fmt.Fprintf(&b, ",\n\tinsert_%d AS (INSERT INTO %s (%s) SELECT %s FROM seed)", i, table, columns, columns)
+	}
+	fmt.Fprintf(&b, "\nINSERT INTO %s (%s) SELECT %s FROM seed", tables[last], columns, columns)
+
+	p := gen.profile
+	_, err := tx.ExecContext(ctx, b.String(), first, first+int64(count)-1, gen.seed,
+		p.Customers.Count,
+		p.Amounts.MinCents, p.Amounts.MaxCents-p.Amounts.MinCents+1,
+		p.TaxRates.Min.Hundredths(), p.TaxRates.Max.Hundredths()-p.TaxRates.Min.Hundredths()+1)
+	if err != nil {
+		return fmt.Errorf("failed to generate rows: %w", err)
+	}
+	return nil
+}
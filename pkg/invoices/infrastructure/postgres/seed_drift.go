@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// tableDrift counts how the rows of a table differ from the primary table's
+type tableDrift struct {
+	missing   int64
+	extra     int64
+	differing int64
+}
+
+func (d tableDrift) any() bool {
+	return d.missing+d.extra+d.differing > 0
+}
+
+// detectDrift compares the rows of table with the rows of primary by ID,
+// except for reserved benchmark IDs
+func detectDrift(ctx context.Context, db *sql.DB, primary, table string) (tableDrift, error) {
+	var d tableDrift
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE t.id IS NULL),
+			COUNT(*) FILTER (WHERE p.id IS NULL),
+			COUNT(*) FILTER (WHERE p.id IS NOT NULL AND t.id IS NOT NULL)
+		FROM `+primary+` p
+		FULL JOIN `+table+` t ON t.id = p.id
+		WHERE (p.id IS NULL OR t.id IS NULL
+			OR (p.customer_id, p.amount_cents, p.tax_rate) IS DISTINCT FROM (t.customer_id, t.amount_cents, t.tax_rate))
+			AND `+outsideBenchmarkIDs("COALESCE(p.id, t.id)")+`
+	`).Scan(&d.missing, &d.extra, &d.differing)
+	if err != nil {
+		return tableDrift{}, fmt.Errorf("failed to compare %s with %s: %w", table, primary, err)
+	}
+	return d, nil
+}
+
+// tableSummary is the row count, highest ID and a checksum of the rows of a
+// table outside reserved benchmark IDs. The checksum sums a hash of every
+// row, so it doesn't depend on row order and changes with any row's ID or
+// values
+type tableSummary struct {
+	count    int64
+	maxID    int64
+	checksum string
+}
+
+func summarizeTable(ctx context.Context, db *sql.DB, table string) (tableSummary, error) {
+	var s tableSummary
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COALESCE(MAX(id), 0),
+			COALESCE(SUM(hashtextextended(id || ',' || customer_id || ',' || amount_cents || ',' || tax_rate, 0)::NUMERIC), 0)::TEXT
+		FROM `+table+` t
+		WHERE `+outsideBenchmarkIDs("t.id")).Scan(&s.count, &s.maxID, &s.checksum)
+	if err != nil {
+		return tableSummary{}, fmt.Errorf("failed to check rows of %s: %w", table, err)
+	}
+	return s, nil
+}
+
+// repairDrift makes the rows of every table identical to the rows of the
+// first, which assigns IDs on writes and is taken as authoritative. Rows
+// missing from a table are copied, rows only in it are deleted and rows with
+// other values are overwritten, one transaction per table. It reports whether
+// any table was repaired.
+//
+// Comparing rows joins whole tables, so it only runs for tables whose
+// summary differs from the first's. Scanning each table for its checksum is
+// far cheaper than the join
+func repairDrift(ctx context.Context, db *sql.DB, tables []string) (bool, error) {
+	primary := tables[0]
+	repaired := false
+
+	primarySummary, err := summarizeTable(ctx, db, primary)
+	if err != nil {
+		return false, err
+	}
+
+	for _, table := range tables[1:] {
+		summary, err := summarizeTable(ctx, db, table)
+		if err != nil {
+			return false, err
+		}
+		if summary == primarySummary {
+			continue
+		}
+
+		d, err := detectDrift(ctx, db, primary, table)
+		if err != nil {
+			return false, err
+		}
+		if !d.any() {
+			continue
+		}
+
+		log.Printf("Repairing drift of %s from %s: %d rows missing, %d extra, %d differing",
+			table, primary, d.missing, d.extra, d.differing)
+		if err := copyRows(ctx, db, primary, table); err != nil {
+			return false, err
+		}
+		repaired = true
+	}
+
+	return repaired, nil
+}
+
+// copyRows makes the rows of table identical to the rows of primary, and
+// advances its id sequence past the copied IDs. Rows with reserved benchmark
+// IDs are left alone
+func copyRows(ctx context.Context, db *sql.DB, primary, table string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE FROM ` + table + ` t
+		WHERE NOT EXISTS (SELECT 1 FROM ` + primary + ` p WHERE p.id = t.id)
+			AND ` + outsideBenchmarkIDs("t.id"),
+
+		`UPDATE ` + table + ` t
+		SET customer_id = p.customer_id, amount_cents = p.amount_cents, tax_rate = p.tax_rate
+		FROM ` + primary + ` p
+		WHERE p.id = t.id
+			AND (p.customer_id, p.amount_cents, p.tax_rate) IS DISTINCT FROM (t.customer_id, t.amount_cents, t.tax_rate)
+			AND ` + outsideBenchmarkIDs("p.id"),
+
+		`INSERT INTO ` + table + ` (id, customer_id, amount_cents, tax_rate)
+		SELECT p.id, p.customer_id, p.amount_cents, p.tax_rate
+		FROM ` + primary + ` p
+		WHERE NOT EXISTS (SELECT 1 FROM ` + table + ` t WHERE t.id = p.id)
+			AND ` + outsideBenchmarkIDs("p.id"),
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to repair %s: %w", table, err)
+		}
+	}
+
+	var maxID int64
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM "+primary).Scan(&maxID); err != nil {
+		return fmt.Errorf("failed to repair %s: %w", table, err)
+	}
+	if err := advanceSequence(ctx, tx, table, maxID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
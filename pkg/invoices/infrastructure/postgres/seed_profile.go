@@ -0,0 +1,353 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// Distributions of seeded columns
+const (
+	DistributionUniform   = "uniform"
+	DistributionZipf      = "zipf"
+	DistributionLogNormal = "lognormal"
+	DistributionFixed     = "fixed"
+)
+
+// maxColumnTaxRate is the largest tax rate in hundredths a NUMERIC(4,2)
+// column holds
+const maxColumnTaxRate = 9999
+
+// SeedProfile describes the distributions seeded rows are drawn from
+type SeedProfile struct {
+	Name      string                `json:"name"`
+	Customers CustomerDistribution  `json:"customers"`
+	Amounts   AmountDistribution    `json:"amounts"`
+	TaxRates  TaxRateDistribution   `json:"tax_rates"`
+	Edges     EdgeValueDistribution `json:"edge_values"`
+}
+
+// CustomerDistribution describes customer_id, drawn from 1 to Count either
+// uniformly or following Zipf's law, where customer 1 is the most frequent
+type CustomerDistribution struct {
+	Distribution string `json:"distribution"`
+	Count        int64  `json:"count"`
+
+	// Skew is the Zipf exponent, greater than 1. Higher is more skewed
+	Skew float64 `json:"skew,omitempty"`
+}
+
+// AmountDistribution describes amount_cents, drawn uniformly from MinCents
+// to MaxCents, or log-normally around MedianCents and clamped to that range
+type AmountDistribution struct {
+	Distribution string  `json:"distribution"`
+	MinCents     int64   `json:"min_cents"`
+	MaxCents     int64   `json:"max_cents"`
+	MedianCents  int64   `json:"median_cents,omitempty"`
+	Sigma        float64 `json:"sigma,omitempty"`
+}
+
+// TaxRateDistribution describes tax_rate, drawn uniformly from Min to Max in
+// steps of 0.01, or from a fixed set of Values with optional relative Weights
+type TaxRateDistribution struct {
+	Distribution string             `json:"distribution"`
+	Min          invoices.TaxRate   `json:"min"`
+	Max          invoices.TaxRate   `json:"max"`
+	Values       []invoices.TaxRate `json:"values,omitempty"`
+	Weights      []float64          `json:"weights,omitempty"`
+}
+
+// EdgeValueDistribution replaces a row's amount, and optionally its tax
+// rate, with one of Rows picked uniformly with the given Probability. Edge
+// values never become NULL, as every seeded column is NOT NULL
+type EdgeValueDistribution struct {
+	Probability float64   `json:"probability"`
+	Rows        []EdgeRow `json:"rows,omitempty"`
+}
+
+// EdgeRow is an edge amount, with the tax rate it must be combined with, if any
+type EdgeRow struct {
+	AmountCents int64             `json:"amount_cents"`
+	TaxRate     *invoices.TaxRate `json:"tax_rate,omitempty"`
+}
+
+// seedProfiles are the built-in profiles selectable by name
+var seedProfiles = map[string]SeedProfile{
+	"uniform": {
+		Name:      "uniform",
+		Customers: CustomerDistribution{Distribution: DistributionUniform, Count: 10000},
+		Amounts:   AmountDistribution{Distribution: DistributionUniform, MinCents: 100, MaxCents: 1000099},
+		TaxRates:  TaxRateDistribution{Distribution: DistributionUniform, Min: invoices.NewTaxRate(1), Max: invoices.NewTaxRate(25)},
+	},
+	"realistic": {
+		Name:      "realistic",
+		Customers: CustomerDistribution{Distribution: DistributionZipf, Count: 100000, Skew: 1.2},
+		Amounts: AmountDistribution{
+			Distribution: DistributionLogNormal, MinCents: 1, MaxCents: 100000000, MedianCents: 4500, Sigma: 1.3,
+		},
+		TaxRates: TaxRateDistribution{
+			Distribution: DistributionFixed,
+			Values: []invoices.TaxRate{
+				invoices.NewTaxRate(0), invoices.NewTaxRate(5), invoices.NewTaxRate(7),
+				invoices.NewTaxRate(19), invoices.NewTaxRate(20),
+			},
+			Weights: []float64{1, 2, 3, 4, 3},
+		},
+	},
+	"edge": {
+		Name:      "edge",
+		Customers: CustomerDistribution{Distribution: DistributionUniform, Count: 10000},
+		Amounts:   AmountDistribution{Distribution: DistributionUniform, MinCents: 100, MaxCents: 1000099},
+		TaxRates:  TaxRateDistribution{Distribution: DistributionUniform, Min: invoices.NewTaxRate(1), Max: invoices.NewTaxRate(25)},
+		Edges: EdgeValueDistribution{
+			Probability: 0.05,
+			Rows: []EdgeRow{
+				{AmountCents: 0},
+				{AmountCents: 1},
+				{AmountCents: -1},
+				{AmountCents: -250000},
+				// Ties at half a cent, where float and banker's rounding diverge
+				{AmountCents: 50, TaxRate: taxRatePtr(13)},
+				{AmountCents: 150, TaxRate: taxRatePtr(13)},
+				{AmountCents: 50, TaxRate: taxRatePtr(1)},
+				{AmountCents: -50, TaxRate: taxRatePtr(13)},
+				// The largest amounts whose totals fit in BIGINT
+				{AmountCents: math.MaxInt64, TaxRate: taxRatePtr(0)},
+				{AmountCents: math.MinInt64, TaxRate: taxRatePtr(0)},
+				{AmountCents: math.MaxInt64 / 100 * 80, TaxRate: taxRatePtr(25)},
+			},
+		},
+	},
+}
+
+func taxRatePtr(hundredths int64) *invoices.TaxRate {
+	rate := invoices.NewTaxRate(hundredths)
+	return &rate
+}
+
+// DefaultSeedProfile is the profile used when none is configured
+const DefaultSeedProfile = "uniform"
+
+// LoadSeedProfile returns the built-in profile with the given name, or reads
+// a profile from the JSON file at that path
+func LoadSeedProfile(nameOrPath string) (SeedProfile, error) {
+	if profile, ok := seedProfiles[nameOrPath]; ok {
+		return profile, nil
+	}
+	if !strings.HasSuffix(nameOrPath, ".json") {
+		return SeedProfile{}, fmt.Errorf("unknown seed profile %q, expected uniform, realistic, edge or a .json file", nameOrPath)
+	}
+
+	f, err := os.Open(nameOrPath)
+	if err != nil {
+		return SeedProfile{}, fmt.Errorf("failed to read seed profile: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+
+	var profile SeedProfile
+	if err := dec.Decode(&profile); err != nil {
+		return SeedProfile{}, fmt.Errorf("failed to parse seed profile %s: %w", nameOrPath, err)
+	}
+	if profile.Name == "" {
+		profile.Name = nameOrPath
+	}
+	return profile, nil
+}
+
+// Validate checks every distribution, and that every amount the profile can
+// produce has a total that fits in BIGINT at the highest tax rate
+func (p SeedProfile) Validate() error {
+	c := p.Customers
+	switch c.Distribution {
+	case DistributionUniform:
+	case DistributionZipf:
+		if c.Skew <= 1 {
+			return fmt.Errorf("seed profile %s: zipf customer skew must be greater than 1", p.Name)
+		}
+	default:
+		return fmt.Errorf("seed profile %s: customers must be %s or %s", p.Name, DistributionUniform, DistributionZipf)
+	}
+	if c.Count < 1 {
+		return fmt.Errorf("seed profile %s: customer count must be positive", p.Name)
+	}
+
+	a := p.Amounts
+	switch a.Distribution {
+	case DistributionUniform:
+	case DistributionLogNormal:
+		if a.MedianCents < 1 || a.Sigma <= 0 {
+			return fmt.Errorf("seed profile %s: lognormal amounts need a positive median_cents and sigma", p.Name)
+		}
+	default:
+		return fmt.Errorf("seed profile %s: amounts must be %s or %s", p.Name, DistributionUniform, DistributionLogNormal)
+	}
+	if a.MinCents > a.MaxCents {
+		return fmt.Errorf("seed profile %s: min_cents is greater than max_cents", p.Name)
+	}
+	if a.MaxCents-a.MinCents < 0 || a.MaxCents-a.MinCents == math.MaxInt64 {
+		return fmt.Errorf("seed profile %s: amount range is too wide", p.Name)
+	}
+
+	t := p.TaxRates
+	var rates []invoices.TaxRate
+	switch t.Distribution {
+	case DistributionUniform:
+		if t.Min.Hundredths() > t.Max.Hundredths() {
+			return fmt.Errorf("seed profile %s: tax rate min is greater than max", p.Name)
+		}
+		rates = []invoices.TaxRate{t.Min, t.Max}
+	case DistributionFixed:
+		if len(t.Values) == 0 {
+			return fmt.Errorf("seed profile %s: fixed tax rates need values", p.Name)
+		}
+		if len(t.Weights) != 0 && len(t.Weights) != len(t.Values) {
+			return fmt.Errorf("seed profile %s: tax rate weights must match values", p.Name)
+		}
+		var total float64
+		for _, w := range t.Weights {
+			if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+				return fmt.Errorf("seed profile %s: tax rate weights must be finite and not negative", p.Name)
+			}
+			total += w
+		}
+		if len(t.Weights) != 0 && total == 0 {
+			return fmt.Errorf("seed profile %s: tax rate weights must not all be zero", p.Name)
+		}
+		rates = t.Values
+	default:
+		return fmt.Errorf("seed profile %s: tax rates must be %s or %s", p.Name, DistributionUniform, DistributionFixed)
+	}
+
+	e := p.Edges
+	if e.Probability < 0 || e.Probability > 1 || math.IsNaN(e.Probability) {
+		return fmt.Errorf("seed profile %s: edge value probability must be between 0 and 1", p.Name)
+	}
+	if e.Probability > 0 && len(e.Rows) == 0 {
+		return fmt.Errorf("seed profile %s: edge values need rows", p.Name)
+	}
+
+	for _, rate := range rates {
+		if err := checkColumnTaxRate(p.Name, rate); err != nil {
+			return err
+		}
+		for _, amount := range []int64{a.MinCents, a.MaxCents} {
+			if _, err := rate.TotalCents(amount, invoices.DefaultRoundingMode); err != nil {
+				return fmt.Errorf("seed profile %s: %w", p.Name, err)
+			}
+		}
+	}
+	for _, edge := range e.Rows {
+		edgeRates := rates
+		if edge.TaxRate != nil {
+			if err := checkColumnTaxRate(p.Name, *edge.TaxRate); err != nil {
+				return err
+			}
+			edgeRates = []invoices.TaxRate{*edge.TaxRate}
+		}
+		for _, rate := range edgeRates {
+			if _, err := rate.TotalCents(edge.AmountCents, invoices.DefaultRoundingMode); err != nil {
+				return fmt.Errorf("seed profile %s: edge value: %w", p.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkColumnTaxRate(profile string, rate invoices.TaxRate) error {
+	if rate.Hundredths() < 0 || rate.Hundredths() > maxColumnTaxRate {
+		return fmt.Errorf("seed profile %s: tax rate %s is outside 0 to 99.99", profile, rate)
+	}
+	return nil
+}
+
+// sqlExpressible reports whether the database can generate the profile's
+// rows, which it can for uniform distributions without edge values
+func (p SeedProfile) sqlExpressible() bool {
+	return p.Customers.Distribution == DistributionUniform &&
+		p.Amounts.Distribution == DistributionUniform &&
+		p.TaxRates.Distribution == DistributionUniform &&
+		p.Edges.Probability == 0
+}
+
+// profileSampler draws column values following a profile from a random
+// source. Every value is drawn in the same order for every row, so rows stay
+// reproducible from their ID
+type profileSampler struct {
+	profile SeedProfile
+	zipf    *rand.Zipf
+
+	// cumulativeWeights of the fixed tax rates, ending with the total
+	cumulativeWeights []float64
+}
+
+func newProfileSampler(profile SeedProfile, r *rand.Rand) *profileSampler {
+	s := &profileSampler{profile: profile}
+	if profile.Customers.Distribution == DistributionZipf {
+		s.zipf = rand.NewZipf(r, profile.Customers.Skew, 1, uint64(profile.Customers.Count-1))
+	}
+	if weights := profile.TaxRates.Weights; len(weights) > 0 {
+		var total float64
+		for _, w := range weights {
+			total += w
+			s.cumulativeWeights = append(s.cumulativeWeights, total)
+		}
+	}
+	return s
+}
+
+func (s *profileSampler) customerID(r *rand.Rand) int64 {
+	if s.zipf != nil {
+		return int64(s.zipf.Uint64()) + 1
+	}
+	return r.Int63n(s.profile.Customers.Count) + 1
+}
+
+func (s *profileSampler) amountCents(r *rand.Rand) int64 {
+	a := s.profile.Amounts
+	if a.Distribution == DistributionLogNormal {
+		v := math.Round(float64(a.MedianCents) * math.Exp(a.Sigma*r.NormFloat64()))
+		switch {
+		case v <= float64(a.MinCents):
+			return a.MinCents
+		case v >= float64(a.MaxCents):
+			return a.MaxCents
+		}
+		return int64(v)
+	}
+	return r.Int63n(a.MaxCents-a.MinCents+1) + a.MinCents
+}
+
+func (s *profileSampler) taxRate(r *rand.Rand) invoices.TaxRate {
+	t := s.profile.TaxRates
+	if t.Distribution == DistributionFixed {
+		if len(s.cumulativeWeights) == 0 {
+			return t.Values[r.Intn(len(t.Values))]
+		}
+		x := r.Float64() * s.cumulativeWeights[len(s.cumulativeWeights)-1]
+		for i, w := range s.cumulativeWeights {
+			if x < w {
+				return t.Values[i]
+			}
+		}
+		return t.Values[len(t.Values)-1]
+	}
+	return invoices.NewTaxRate(int64(r.Intn(int(t.Max.Hundredths()-t.Min.Hundredths()+1))) + t.Min.Hundredths())
+}
+
+// edge returns the edge row replacing the current row's values, if any
+func (s *profileSampler) edge(r *rand.Rand) (EdgeRow, bool) {
+	e := s.profile.Edges
+	if e.Probability == 0 || r.Float64() >= e.Probability {
+		return EdgeRow{}, false
+	}
+	return e.Rows[r.Intn(len(e.Rows))], true
+}
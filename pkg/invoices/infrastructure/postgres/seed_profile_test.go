@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+func TestBuiltinSeedProfilesAreValid(t *testing.T) {
+	for name, profile := range seedProfiles {
+		if err := profile.Validate(); err != nil {
+			t.Errorf("profile %s: Validate() error = %v", name, err)
+		}
+	}
+}
+
+func TestSeedProfileValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *SeedProfile)
+		err    string
+	}{
+		{
+			name:   "unknown customer distribution",
+			modify: func(p *SeedProfile) { p.Customers.Distribution = "normal" },
+			err:    "customers must be uniform or zipf",
+		},
+		{
+			name: "zipf skew of 1",
+			modify: func(p *SeedProfile) {
+				p.Customers.Distribution = DistributionZipf
+				p.Customers.Skew = 1
+			},
+			err: "skew must be greater than 1",
+		},
+		{
+			name:   "no customers",
+			modify: func(p *SeedProfile) { p.Customers.Count = 0 },
+			err:    "customer count must be positive",
+		},
+		{
+			name:   "unknown amount distribution",
+			modify: func(p *SeedProfile) { p.Amounts.Distribution = DistributionZipf },
+			err:    "amounts must be uniform or lognormal",
+		},
+		{
+			name: "lognormal without median",
+			modify: func(p *SeedProfile) {
+				p.Amounts.Distribution = DistributionLogNormal
+				p.Amounts.Sigma = 1
+			},
+			err: "positive median_cents and sigma",
+		},
+		{
+			name: "lognormal without sigma",
+			modify: func(p *SeedProfile) {
+				p.Amounts.Distribution = DistributionLogNormal
+				p.Amounts.MedianCents = 100
+			},
+			err: "positive median_cents and sigma",
+		},
+		{
+			name:   "amount min above max",
+			modify: func(p *SeedProfile) { p.Amounts.MinCents = p.Amounts.MaxCents + 1 },
+			err:    "min_cents is greater than max_cents",
+		},
+		{
+			name: "amount range overflowing",
+			modify: func(p *SeedProfile) {
+				p.Amounts.MinCents = math.MinInt64
+				p.Amounts.MaxCents = math.MaxInt64
+			},
+			err: "amount range is too wide",
+		},
+		{
+			name:   "amount total overflowing",
+			modify: func(p *SeedProfile) { p.Amounts.MaxCents = math.MaxInt64 },
+			err:    "total out of range",
+		},
+		{
+			name:   "unknown tax rate distribution",
+			modify: func(p *SeedProfile) { p.TaxRates.Distribution = DistributionLogNormal },
+			err:    "tax rates must be uniform or fixed",
+		},
+		{
+			name:   "tax rate min above max",
+			modify: func(p *SeedProfile) { p.TaxRates.Min = invoices.NewTaxRate(30) },
+			err:    "tax rate min is greater than max",
+		},
+		{
+			name:   "tax rate above column",
+			modify: func(p *SeedProfile) { p.TaxRates.Max = invoices.NewTaxRate(10000) },
+			err:    "outside 0 to 99.99",
+		},
+		{
+			name:   "negative tax rate",
+			modify: func(p *SeedProfile) { p.TaxRates.Min = invoices.NewTaxRate(-1) },
+			err:    "outside 0 to 99.99",
+		},
+		{
+			name:   "fixed without values",
+			modify: func(p *SeedProfile) { p.TaxRates = TaxRateDistribution{Distribution: DistributionFixed} },
+			err:    "fixed tax rates need values",
+		},
+		{
+			name: "weights not matching values",
+			modify: func(p *SeedProfile) {
+				p.TaxRates = fixedTaxRates([]float64{1}, 5, 7)
+			},
+			err: "weights must match values",
+		},
+		{
+			name: "negative weight",
+			modify: func(p *SeedProfile) {
+				p.TaxRates = fixedTaxRates([]float64{1, -1}, 5, 7)
+			},
+			err: "finite and not negative",
+		},
+		{
+			name: "infinite weight",
+			modify: func(p *SeedProfile) {
+				p.TaxRates = fixedTaxRates([]float64{1, math.Inf(1)}, 5, 7)
+			},
+			err: "finite and not negative",
+		},
+		{
+			name: "zero weights",
+			modify: func(p *SeedProfile) {
+				p.TaxRates = fixedTaxRates([]float64{0, 0}, 5, 7)
+			},
+			err: "must not all be zero",
+		},
+		{
+			name:   "edge probability above 1",
+			modify: func(p *SeedProfile) { p.Edges.Probability = 1.5 },
+			err:    "between 0 and 1",
+		},
+		{
+			name:   "edge probability NaN",
+			modify: func(p *SeedProfile) { p.Edges.Probability = math.NaN() },
+			err:    "between 0 and 1",
+		},
+		{
+			name: "edge probability without rows",
+			modify: func(p *SeedProfile) {
+				p.Edges = EdgeValueDistribution{Probability: 0.1}
+			},
+			err: "edge values need rows",
+		},
+		{
+			name: "edge total overflowing",
+			modify: func(p *SeedProfile) {
+				p.Edges = EdgeValueDistribution{Probability: 0.1, Rows: []EdgeRow{{AmountCents: math.MaxInt64}}}
+			},
+			err: "edge value: total out of range",
+		},
+		{
+			name: "edge tax rate above column",
+			modify: func(p *SeedProfile) {
+				p.Edges = EdgeValueDistribution{Probability: 0.1, Rows: []EdgeRow{{AmountCents: 1, TaxRate: taxRatePtr(10000)}}}
+			},
+			err: "outside 0 to 99.99",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := seedProfiles["uniform"]
+			profile.Name = "test"
+			tt.modify(&profile)
+
+			err := profile.Validate()
+			if err == nil {
+				t.Fatalf("Validate() succeeded, want error containing %q", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Validate() error = %q, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func fixedTaxRates(weights []float64, hundredths ...int64) TaxRateDistribution {
+	t := TaxRateDistribution{Distribution: DistributionFixed, Weights: weights}
+	for _, h := range hundredths {
+		t.Values = append(t.Values, invoices.NewTaxRate(h))
+	}
+	return t
+}
@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// seedSchema creates the tables recording seeding progress. A run plans the
+// ID range it seeds and the settings its rows are derived from, and every
+// batch is recorded in the transaction writing it
+const seedSchema = `
+	CREATE TABLE IF NOT EXISTS seed_runs (
+		id           BIGSERIAL PRIMARY KEY,
+		first_id     BIGINT NOT NULL,
+		row_count    BIGINT NOT NULL,
+		batch_size   INTEGER NOT NULL,
+		mode         TEXT NOT NULL,
+		random_seed  BIGINT NOT NULL,
+		profile      JSONB NOT NULL,
+		started_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		completed_at TIMESTAMPTZ
+	);
+
+	CREATE TABLE IF NOT EXISTS seed_batches (
+		run_id       BIGINT NOT NULL REFERENCES seed_runs (id),
+		first_id     BIGINT NOT NULL,
+		row_count    INTEGER NOT NULL,
+		committed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (run_id, first_id)
+	);
+`
+
+// seedLockKey is the advisory lock held while seeding, so concurrent seeds
+// don't plan overlapping runs
+const seedLockKey = 0x5eed
+
+// ErrSeedInProgress is returned when another process is seeding the database
+var ErrSeedInProgress = errors.New("another seed is in progress")
+
+// seedRun is a planned range of seeded rows. Its batches are written in any
+// order, and resuming it writes exactly the batches not yet committed, with
+// the same values
+type seedRun struct {
+	id         int64
+	firstID    int64
+	count      int64
+	batchSize  int
+	mode       SeedMode
+	randomSeed int64
+	profile    SeedProfile
+}
+
+// lastID returns the highest ID of the run
+func (r seedRun) lastID() int64 {
+	return r.firstID + r.count - 1
+}
+
+// lockSeeding takes the seeding advisory lock on a dedicated connection and
+// returns a function releasing it. Returns ErrSeedInProgress if another
+// session holds it
+func lockSeeding(ctx context.Context, db *sql.DB) (func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock seeding: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", seedLockKey).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to lock seeding: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, ErrSeedInProgress
+	}
+
+	return func() {
+		// Session locks outlive the connection's return to the pool
+		conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", seedLockKey)
+		conn.Close()
+	}, nil
+}
+
+// incompleteSeedRun returns the latest run that has not completed, if any
+func incompleteSeedRun(ctx context.Context, db *sql.DB) (seedRun, bool, error) {
+	var run seedRun
+	var profile []byte
+	err := db.QueryRowContext(ctx, `
+		SELECT id, first_id, row_count, batch_size, mode, random_seed, profile
+		FROM seed_runs
+		WHERE completed_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&run.id, &run.firstID, &run.count, &run.batchSize, &run.mode, &run.randomSeed, &profile)
+	if errors.Is(err, sql.ErrNoRows) {
+		return seedRun{}, false, nil
+	}
+	if err != nil {
+		return seedRun{}, false, fmt.Errorf("failed to check seed progress: %w", err)
+	}
+
+	if err := json.Unmarshal(profile, &run.profile); err != nil {
+		return seedRun{}, false, fmt.Errorf("failed to read profile of seed run %d: %w", run.id, err)
+	}
+	if _, ok := seedModes[run.mode]; !ok {
+		return seedRun{}, false, fmt.Errorf("seed run %d has unknown mode %q", run.id, run.mode)
+	}
+	return run, true, nil
+}
+
+// otherSeedMode returns the mode of the latest recorded run in none of the
+// given modes, if any
+func otherSeedMode(ctx context.Context, db *sql.DB, modes []SeedMode) (SeedMode, bool, error) {
+	names := make([]string, len(modes))
+	for i, mode := range modes {
+		names[i] = string(mode)
+	}
+
+	var other SeedMode
+	err := db.QueryRowContext(ctx, "SELECT mode FROM seed_runs WHERE mode <> ALL($1) ORDER BY id DESC LIMIT 1", pq.Array(names)).Scan(&other)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check seed runs: %w", err)
+	}
+	return other, true, nil
+}
+
+// startSeedRun reserves the run's ID range from the sequence of the first
+// table, records the run and advances the id sequence of every other table
+// past the range in one transaction, so rows written outside of seeding never
+// take IDs the run is going to write
+func startSeedRun(ctx context.Context, db *sql.DB, tables []string, run seedRun) (seedRun, error) {
+	profile, err := json.Marshal(run.profile)
+	if err != nil {
+		return seedRun{}, fmt.Errorf("failed to encode seed profile: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return seedRun{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if run.firstID, err = reserveIDs(ctx, tx, tables, run.count); err != nil {
+		return seedRun{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO seed_runs (first_id, row_count, batch_size, mode, random_seed, profile)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, run.firstID, run.count, run.batchSize, string(run.mode), run.randomSeed, profile).Scan(&run.id)
+	if err != nil {
+		return seedRun{}, fmt.Errorf("failed to record seed run: %w", err)
+	}
+
+	for _, table := range tables[1:] {
+		if err := advanceSequence(ctx, tx, table, run.lastID()); err != nil {
+			return seedRun{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return seedRun{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return run, nil
+}
+
+// committedSeedBatches returns the first IDs of the run's committed batches
+// and the number of rows they hold
+func committedSeedBatches(ctx context.Context, db *sql.DB, run seedRun) (map[int64]bool, int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT first_id, row_count FROM seed_batches WHERE run_id = $1", run.id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read seed progress: %w", err)
+	}
+	defer rows.Close()
+
+	committed := make(map[int64]bool)
+	var count int64
+	for rows.Next() {
+		var first, n int64
+		if err := rows.Scan(&first, &n); err != nil {
+			return nil, 0, fmt.Errorf("failed to read seed progress: %w", err)
+		}
+		committed[first] = true
+		count += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read seed progress: %w", err)
+	}
+	return committed, count, nil
+}
+
+// recordSeedBatch marks a batch of the run as written, in the transaction
+// writing it
+func recordSeedBatch(ctx context.Context, tx *sql.Tx, run seedRun, first int64, count int) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO seed_batches (run_id, first_id, row_count) VALUES ($1, $2, $3)", run.id, first, count)
+	if err != nil {
+		return fmt.Errorf("failed to record seed batch: %w", err)
+	}
+	return nil
+}
+
+// completeSeedRun marks the run completed once all of its rows are committed
+func completeSeedRun(ctx context.Context, db *sql.DB, run seedRun) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE seed_runs SET completed_at = now()
+		WHERE id = $1 AND row_count = (SELECT COALESCE(SUM(row_count), 0) FROM seed_batches WHERE run_id = $1)
+	`, run.id)
+	if err != nil {
+		return fmt.Errorf("failed to complete seed run %d: %w", run.id, err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("seed run %d has uncommitted batches", run.id)
+	}
+	return nil
+}
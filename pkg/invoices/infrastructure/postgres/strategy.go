@@ -0,0 +1,419 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// Strategy describes how a total computation approach is stored and queried
+type Strategy struct {
+	invoices.Strategy
+
+	// Table is the relation rows are read from
+	Table string
+
+	// Schema is the DDL creating the relation and its supporting objects.
+	// It must be idempotent, as it runs on every startup
+	Schema string
+
+	// FetchQuery selects the strategy's columns from its relation. It must not
+	// have WHERE, ORDER BY or LIMIT clauses; the repository appends filters,
+	// ordering by id and paging for each fetch
+	FetchQuery string
+
+	// ScanRow maps the current row of a FetchQuery result to an invoice
+	ScanRow func(rows *sql.Rows) (*invoices.Invoice, error)
+
+	// HasTotalColumn reports whether Table has a total_cents column computed
+	// by the database. ScanRow must then expect it after the other columns
+	HasTotalColumn bool
+
+	// RefreshQuery recomputes precomputed data. Required for refreshable strategies
+	RefreshQuery string
+}
+
+// Statement kinds distinguishing a strategy's tagged statements
+const (
+	statementFetch  = "fetch"
+	statementLookup = "lookup"
+)
+
+// taggedFetchQuery returns the fetch statement for q prefixed with a comment
+// identifying the strategy, so server-side statement statistics can be
+// attributed to it
+func (s Strategy) taggedFetchQuery(q invoices.Query) (string, []any) {
+	query, args := buildFetchQuery(s.FetchQuery, q)
+	return statementTag(s.Name, statementFetch) + query, args
+}
+
+// taggedLookupQuery returns the statement fetching a single row by id,
+// bound to $1. Its tag differs from the fetch statement's, so lookups are not
+// counted as fetches
+func (s Strategy) taggedLookupQuery() string {
+	return statementTag(s.Name, statementLookup) + strings.TrimSpace(s.FetchQuery) + "\nWHERE id = $1"
+}
+
+// statementTag returns the comment prefixed to a strategy's statements of
+// the given kind
+func statementTag(name, kind string) string {
+	return "/* strategy:" + name + ":" + kind + " */"
+}
+
+// Registry holds total computation strategies in registration order
+type Registry struct {
+	strategies []Strategy
+	byName     map[string]int
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]int)}
+}
+
+// DefaultRegistry creates a Registry with all built-in strategies, where
+// totals calculated in Go are rounded with rounding. Strategies are
+// registered after the tables their schema depends on
+func DefaultRegistry(rounding invoices.RoundingMode) *Registry {
+	r := NewRegistry()
+	r.MustRegister(StoredStrategy())
+	r.MustRegister(TrueVirtualStrategy())
+	r.MustRegister(CalculatedStrategy(rounding))
+	r.MustRegister(SQLExpressionStrategy())
+	r.MustRegister(TriggerStrategy())
+	r.MustRegister(ViewStrategy())
+	r.MustRegister(MaterializedViewStrategy())
+	return r
+}
+
+// Register adds a strategy to the registry
+func (r *Registry) Register(s Strategy) error {
+	if s.Name == "" {
+		return fmt.Errorf("strategy name is required")
+	}
+	if s.Table == "" || s.FetchQuery == "" || s.ScanRow == nil {
+		return fmt.Errorf("strategy %q: table, fetch query and row mapper are required", s.Name)
+	}
+	if s.Refreshable != (s.RefreshQuery != "") {
+		return fmt.Errorf("strategy %q: refresh query must be set if and only if the strategy is refreshable", s.Name)
+	}
+	if _, ok := r.byName[s.Name]; ok {
+		return fmt.Errorf("strategy %q already registered", s.Name)
+	}
+
+	r.byName[s.Name] = len(r.strategies)
+	r.strategies = append(r.strategies, s)
+	return nil
+}
+
+// MustRegister adds a strategy to the registry and panics on error
+func (r *Registry) MustRegister(s Strategy) {
+	if err := r.Register(s); err != nil {
+		panic(err)
+	}
+}
+
+// Get returns the strategy registered under name
+func (r *Registry) Get(name string) (Strategy, error) {
+	i, ok := r.byName[name]
+	if !ok {
+		return Strategy{}, fmt.Errorf("%w: %s", invoices.ErrStrategyNotFound, name)
+	}
+	return r.strategies[i], nil
+}
+
+// All returns the registered strategies in registration order
+func (r *Registry) All() []Strategy {
+	return append([]Strategy(nil), r.strategies...)
+}
+
+// StoredStrategy reads total_cents from a STORED generated column
+func StoredStrategy() Strategy {
+	return Strategy{
+		Strategy: invoices.Strategy{
+			Name:        "virtual",
+			Description: "PostgreSQL STORED generated column",
+			Computation: invoices.ComputedOnWrite,
+			Writable:    true,
+		},
+		Table:          "invoices_with_virtual",
+		HasTotalColumn: true,
+		Schema: `
+		CREATE TABLE IF NOT EXISTS invoices_with_virtual (
+			id           BIGSERIAL PRIMARY KEY,
+			customer_id  BIGINT NOT NULL,
+			amount_cents BIGINT NOT NULL,
+			tax_rate     NUMERIC(4,2) NOT NULL,
+			total_cents  BIGINT GENERATED ALWAYS AS (
+				ROUND(amount_cents * (1 + tax_rate))
+			) STORED
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_invoices_with_virtual_customer ON invoices_with_virtual(customer_id);
+		`,
+		FetchQuery: `
+		SELECT id, customer_id, amount_cents, tax_rate, total_cents
+		FROM invoices_with_virtual
+		`,
+		ScanRow: scanWithTotal,
+	}
+}
+
+// TrueVirtualStrategy reads total_cents from a PostgreSQL 18 VIRTUAL
+// generated column, computed on every read
+func TrueVirtualStrategy() Strategy {
+	return Strategy{
+		Strategy: invoices.Strategy{
+			Name:        "true-virtual",
+			Description: "PostgreSQL 18 VIRTUAL generated column",
+			Computation: invoices.ComputedOnRead,
+			Writable:    true,
+		},
+		Table:          "invoices_with_true_virtual",
+		HasTotalColumn: true,
+		Schema: `
+		CREATE TABLE IF NOT EXISTS invoices_with_true_virtual (
+			id           BIGSERIAL PRIMARY KEY,
+			customer_id  BIGINT NOT NULL,
+			amount_cents BIGINT NOT NULL,
+			tax_rate     NUMERIC(4,2) NOT NULL,
+			total_cents  BIGINT GENERATED ALWAYS AS (
+				ROUND(amount_cents * (1 + tax_rate))
+			) VIRTUAL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_invoices_with_true_virtual_customer ON invoices_with_true_virtual(customer_id);
+		`,
+		FetchQuery: `
+		SELECT id, customer_id, amount_cents, tax_rate, total_cents
+		FROM invoices_with_true_virtual
+		`,
+		ScanRow: scanWithTotal,
+	}
+}
+
+// CalculatedStrategy reads the raw columns and calculates total_cents in Go,
+// rounded to whole cents with rounding
+func CalculatedStrategy(rounding invoices.RoundingMode) Strategy {
+	return Strategy{
+		Strategy: invoices.Strategy{
+			Name:        "calculated",
+			Description: "total_cents calculated in Go",
+			Computation: invoices.ComputedInApplication,
+			Rounding:    rounding,
+			Writable:    true,
+		},
+		Table: "invoices_without_virtual",
+		Schema: `
+		CREATE TABLE IF NOT EXISTS invoices_without_virtual (
+			id           BIGSERIAL PRIMARY KEY,
+			customer_id  BIGINT NOT NULL,
+			amount_cents BIGINT NOT NULL,
+			tax_rate     NUMERIC(4,2) NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_invoices_without_virtual_customer ON invoices_without_virtual(customer_id);
+		`,
+		FetchQuery: `
+		SELECT id, customer_id, amount_cents, tax_rate
+		FROM invoices_without_virtual
+		`,
+		ScanRow: scanCalculated(rounding),
+	}
+}
+
+// SQLExpressionStrategy reads from the calculated strategy's table but
+// computes total_cents in the SELECT list, separating database-side
+// computation from generated column storage
+func SQLExpressionStrategy() Strategy {
+	return Strategy{
+		Strategy: invoices.Strategy{
+			Name:        "sql-expression",
+			Description: "total_cents computed in the SELECT list",
+			Computation: invoices.ComputedOnRead,
+		},
+		Table: "invoices_without_virtual",
+		FetchQuery: `
+		SELECT id, customer_id, amount_cents, tax_rate,
+			ROUND(amount_cents * (1 + tax_rate))::BIGINT AS total_cents
+		FROM invoices_without_virtual
+		`,
+		ScanRow: scanWithTotal,
+	}
+}
+
+// TriggerStrategy reads total_cents from a plain column kept up to date by a
+// BEFORE INSERT OR UPDATE trigger, for comparing trigger write cost against
+// a STORED generated column
+func TriggerStrategy() Strategy {
+	return Strategy{
+		Strategy: invoices.Strategy{
+			Name:        "trigger",
+			Description: "Plain column maintained by a BEFORE INSERT OR UPDATE trigger",
+			Computation: invoices.ComputedOnWrite,
+			Writable:    true,
+		},
+		Table:          "invoices_with_trigger",
+		HasTotalColumn: true,
+		Schema: `
+		CREATE TABLE IF NOT EXISTS invoices_with_trigger (
+			id           BIGSERIAL PRIMARY KEY,
+			customer_id  BIGINT NOT NULL,
+			amount_cents BIGINT NOT NULL,
+			tax_rate     NUMERIC(4,2) NOT NULL,
+			total_cents  BIGINT NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_invoices_with_trigger_customer ON invoices_with_trigger(customer_id);
+
+		CREATE OR REPLACE FUNCTION invoices_with_trigger_set_total() RETURNS trigger AS $$
+		BEGIN
+			NEW.total_cents := ROUND(NEW.amount_cents * (1 + NEW.tax_rate));
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		CREATE OR REPLACE TRIGGER invoices_with_trigger_set_total
+			BEFORE INSERT OR UPDATE ON invoices_with_trigger
+			FOR EACH ROW EXECUTE FUNCTION invoices_with_trigger_set_total();
+		`,
+		FetchQuery: `
+		SELECT id, customer_id, amount_cents, tax_rate, total_cents
+		FROM invoices_with_trigger
+		`,
+		ScanRow: scanWithTotal,
+	}
+}
+
+// ViewStrategy reads total_cents from a plain VIEW over the calculated
+// strategy's table, computed on every read
+func ViewStrategy() Strategy {
+	return Strategy{
+		Strategy: invoices.Strategy{
+			Name:        "view",
+			Description: "VIEW exposing total_cents over invoices_without_virtual",
+			Computation: invoices.ComputedOnRead,
+		},
+		Table:          "invoices_view",
+		HasTotalColumn: true,
+		Schema: `
+		CREATE OR REPLACE VIEW invoices_view AS
+		SELECT id, customer_id, amount_cents, tax_rate,
+			ROUND(amount_cents * (1 + tax_rate))::BIGINT AS total_cents
+		FROM invoices_without_virtual;
+		`,
+		FetchQuery: `
+		SELECT id, customer_id, amount_cents, tax_rate, total_cents
+		FROM invoices_view
+		`,
+		ScanRow: scanWithTotal,
+	}
+}
+
+// MaterializedViewStrategy reads total_cents from a MATERIALIZED VIEW over
+// the calculated strategy's table, computed when the view is refreshed. The
+// unique index on id lets it refresh concurrently, without blocking reads
+func MaterializedViewStrategy() Strategy {
+	return Strategy{
+		Strategy: invoices.Strategy{
+			Name:        "materialized-view",
+			Description: "MATERIALIZED VIEW exposing total_cents over invoices_without_virtual",
+			Computation: invoices.ComputedOnWrite,
+			Refreshable: true,
+		},
+		Table:          "invoices_materialized_view",
+		HasTotalColumn: true,
+		Schema: `
+		CREATE MATERIALIZED VIEW IF NOT EXISTS invoices_materialized_view AS
+		SELECT id, customer_id, amount_cents, tax_rate,
+			ROUND(amount_cents * (1 + tax_rate))::BIGINT AS total_cents
+		FROM invoices_without_virtual
+		WITH DATA;
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_materialized_view_id ON invoices_materialized_view(id);
+		CREATE INDEX IF NOT EXISTS idx_invoices_materialized_view_customer ON invoices_materialized_view(customer_id);
+		`,
+		FetchQuery: `
+		SELECT id, customer_id, amount_cents, tax_rate, total_cents
+		FROM invoices_materialized_view
+		`,
+		ScanRow:      scanWithTotal,
+		RefreshQuery: "REFRESH MATERIALIZED VIEW CONCURRENTLY invoices_materialized_view",
+	}
+}
+
+// scanWithTotal maps a row whose total was computed by the database
+func scanWithTotal(rows *sql.Rows) (*invoices.Invoice, error) {
+	var id int64
+	var customerID int64
+	var amountCents int64
+	var taxRate taxRateColumn
+	var totalCents int64
+
+	if err := rows.Scan(&id, &customerID, &amountCents, &taxRate, &totalCents); err != nil {
+		return nil, err
+	}
+
+	return invoices.NewInvoice(
+		invoices.ID(id),
+		customerID,
+		amountCents,
+		taxRate.TaxRate,
+		totalCents,
+	), nil
+}
+
+// scanCalculated returns a mapper of rows without a total, which calculates
+// it in Go rounded with rounding
+func scanCalculated(rounding invoices.RoundingMode) func(rows *sql.Rows) (*invoices.Invoice, error) {
+	return func(rows *sql.Rows) (*invoices.Invoice, error) {
+		var id int64
+		var customerID int64
+		var amountCents int64
+		var taxRate taxRateColumn
+
+		if err := rows.Scan(&id, &customerID, &amountCents, &taxRate); err != nil {
+			return nil, err
+		}
+
+		return invoices.NewInvoiceWithCalculation(
+			invoices.ID(id),
+			customerID,
+			amountCents,
+			taxRate.TaxRate,
+			rounding,
+		)
+	}
+}
+
+// taxRateColumn scans a NUMERIC tax_rate column exactly from its text form.
+// Valid is false if the column was NULL
+type taxRateColumn struct {
+	invoices.TaxRate
+	Valid bool
+}
+
+// Scan implements sql.Scanner
+func (c *taxRateColumn) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*c = taxRateColumn{}
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("cannot scan %T into a tax rate", src)
+	}
+
+	rate, err := invoices.ParseTaxRate(text)
+	if err != nil {
+		return err
+	}
+	*c = taxRateColumn{TaxRate: rate, Valid: true}
+	return nil
+}
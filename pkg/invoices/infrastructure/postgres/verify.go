@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// PairTotals fully joins the fetch queries of both strategies by id, so rows
+// missing from either side are reported too. Both sides are read in a single
+// statement, and so from the same snapshot. Reserved benchmark IDs are
+// skipped, as a write benchmark holds their rows in one table at a time
+func (r *Repository) PairTotals(ctx context.Context, database, application string, fn func(invoices.TotalPair) error) error {
+	dbStrategy, err := r.registry.Get(database)
+	if err != nil {
+		return err
+	}
+	appStrategy, err := r.registry.Get(application)
+	if err != nil {
+		return err
+	}
+	if dbStrategy.Computation == invoices.ComputedInApplication {
+		return fmt.Errorf("%w: %s does not compute totals in the database", invoices.ErrInvalidComparison, database)
+	}
+	if appStrategy.Computation != invoices.ComputedInApplication {
+		return fmt.Errorf("%w: %s does not compute totals in Go", invoices.ErrInvalidComparison, application)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `/* verify:`+database+`:`+application+` */
+		SELECT
+			d.id, d.customer_id, d.amount_cents, d.tax_rate, d.total_cents,
+			a.id, a.customer_id, a.amount_cents, a.tax_rate
+		FROM (`+strings.TrimSpace(dbStrategy.FetchQuery)+`) d
+		FULL JOIN (`+strings.TrimSpace(appStrategy.FetchQuery)+`) a ON a.id = d.id
+		WHERE `+outsideBenchmarkIDs("COALESCE(d.id, a.id)")+`
+		ORDER BY COALESCE(d.id, a.id)
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		pair, err := scanTotalPair(rows, appStrategy.Rounding)
+		if err != nil {
+			return err
+		}
+		if err := fn(pair); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// scanTotalPair maps a row of the PairTotals join. The database side keeps
+// the total it computed, the application side calculates it in Go like the
+// application strategy's ScanRow, with its rounding
+func scanTotalPair(rows *sql.Rows, rounding invoices.RoundingMode) (invoices.TotalPair, error) {
+	var dbID, dbCustomerID, dbAmountCents, dbTotalCents sql.NullInt64
+	var appID, appCustomerID, appAmountCents sql.NullInt64
+	var dbTaxRate, appTaxRate taxRateColumn
+
+	err := rows.Scan(
+		&dbID, &dbCustomerID, &dbAmountCents, &dbTaxRate, &dbTotalCents,
+		&appID, &appCustomerID, &appAmountCents, &appTaxRate,
+	)
+	if err != nil {
+		return invoices.TotalPair{}, err
+	}
+
+	var pair invoices.TotalPair
+	if dbID.Valid {
+		pair.Database = invoices.NewInvoice(
+			invoices.ID(dbID.Int64),
+			dbCustomerID.Int64,
+			dbAmountCents.Int64,
+			dbTaxRate.TaxRate,
+			dbTotalCents.Int64,
+		)
+	}
+	if appID.Valid {
+		pair.Application, err = invoices.NewInvoiceWithCalculation(
+			invoices.ID(appID.Int64),
+			appCustomerID.Int64,
+			appAmountCents.Int64,
+			appTaxRate.TaxRate,
+			rounding,
+		)
+		if err != nil {
+			return invoices.TotalPair{}, err
+		}
+	}
+	return pair, nil
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"io"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/decimal128"
+	"github.com/apache/arrow/go/v17/arrow/ipc"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// arrowBatchRows is the number of rows per Arrow record batch
+const arrowBatchRows = 65536
+
+// arrowSchema describes the invoice columns, named like the JSON fields. The
+// tax rate is a decimal of the same precision and scale as the column
+var arrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "customer_id", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "amount_cents", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "tax_rate", Type: &arrow.Decimal128Type{Precision: 4, Scale: 2}},
+	{Name: "total_cents", Type: arrow.PrimitiveTypes.Int64},
+}, nil)
+
+// arrowEncoder writes invoices as an Arrow IPC stream. Rows are appended
+// straight from the domain invoices to column builders and written as a
+// record batch every arrowBatchRows rows, or when flushed
+type arrowEncoder struct {
+	writer  *ipc.Writer
+	builder *array.RecordBuilder
+
+	id          *array.Int64Builder
+	customerID  *array.Int64Builder
+	amountCents *array.Int64Builder
+	taxRate     *array.Decimal128Builder
+	totalCents  *array.Int64Builder
+	pending     int
+}
+
+func newArrowEncoder(w io.Writer) rowEncoder {
+	mem := memory.NewGoAllocator()
+	builder := array.NewRecordBuilder(mem, arrowSchema)
+	return &arrowEncoder{
+		writer:      ipc.NewWriter(w, ipc.WithSchema(arrowSchema), ipc.WithAllocator(mem)),
+		builder:     builder,
+		id:          builder.Field(0).(*array.Int64Builder),
+		customerID:  builder.Field(1).(*array.Int64Builder),
+		amountCents: builder.Field(2).(*array.Int64Builder),
+		taxRate:     builder.Field(3).(*array.Decimal128Builder),
+		totalCents:  builder.Field(4).(*array.Int64Builder),
+	}
+}
+
+func (e *arrowEncoder) begin() error { return nil }
+
+func (e *arrowEncoder) encode(inv *invoices.Invoice) error {
+	e.id.Append(int64(inv.ID()))
+	e.customerID.Append(inv.CustomerID())
+	e.amountCents.Append(inv.AmountCents())
+	e.taxRate.Append(decimal128.FromI64(inv.TaxRate().Hundredths()))
+	e.totalCents.Append(inv.TotalCents())
+
+	e.pending++
+	if e.pending == arrowBatchRows {
+		return e.flush()
+	}
+	return nil
+}
+
+// flush writes the pending rows as a record batch
+func (e *arrowEncoder) flush() error {
+	if e.pending == 0 {
+		return nil
+	}
+	e.pending = 0
+
+	record := e.builder.NewRecord()
+	defer record.Release()
+	return e.writer.Write(record)
+}
+
+// end writes the remaining rows and the end-of-stream marker. The schema is
+// written even when there are no rows
+func (e *arrowEncoder) end() error {
+	defer e.builder.Release()
+	if err := e.flush(); err != nil {
+		return err
+	}
+	return e.writer.Close()
+}
@@ -0,0 +1,307 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/cmd"
+	common_http "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/http"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+const (
+	defaultBenchmarkIterations = 5
+	maxBenchmarkIterations     = 1000
+	defaultBenchmarkWarmup     = 1
+	maxBenchmarkWarmup         = 100
+
+	metricPayloadBytes = "response_payload_bytes"
+	metricWireBytes    = "response_wire_bytes"
+	metricEncodeTimeMs = "encode_time_ms"
+)
+
+// SummaryView represents the distribution of a metric over repeated runs
+type SummaryView struct {
+	N      int     `json:"n"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	StdDev float64 `json:"stddev"`
+	CILow  float64 `json:"ci95_low"`
+	CIHigh float64 `json:"ci95_high"`
+}
+
+func toSummaryView(s application.Summary) SummaryView {
+	return SummaryView(s)
+}
+
+// BenchmarkMetrics holds summarised metrics for a single strategy
+type BenchmarkMetrics struct {
+	Strategy    string                 `json:"strategy"`
+	Computation string                 `json:"computation"`
+	RowCount    int                    `json:"row_count"`
+	Metrics     map[string]SummaryView `json:"metrics"`
+	Explain     *ExplainView           `json:"explain,omitempty"`
+}
+
+// BenchmarkDiff compares a strategy's mean metrics against the baseline
+// (positive = slower or larger than baseline)
+type BenchmarkDiff struct {
+	Strategy          string  `json:"strategy"`
+	QueryTimeDiffMs   float64 `json:"query_time_diff_ms"`
+	QueryTimeDiffPct  float64 `json:"query_time_diff_pct"`
+	TotalTimeDiffMs   float64 `json:"total_time_diff_ms"`
+	TotalTimeDiffPct  float64 `json:"total_time_diff_pct"`
+	MemoryDiffBytes   float64 `json:"memory_diff_bytes"`
+	MemoryDiffPct     float64 `json:"memory_diff_pct"`
+	ResponseDiffBytes float64 `json:"response_wire_diff_bytes"`
+	ResponseDiffPct   float64 `json:"response_wire_diff_pct"`
+}
+
+// MetricComparisonView compares the two best strategies for a metric
+type MetricComparisonView struct {
+	Metric      string  `json:"metric"`
+	Best        string  `json:"best"`
+	RunnerUp    string  `json:"runner_up"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
+// BenchmarkComparison compares all registered strategies over repeated runs
+type BenchmarkComparison struct {
+	Iterations int                `json:"iterations"`
+	Warmup     int                `json:"warmup"`
+	Order      string             `json:"order"`
+	Stream     bool               `json:"stream"`
+	Results    []BenchmarkMetrics `json:"results"`
+	Comparison struct {
+		Baseline    string                 `json:"baseline"`
+		Diffs       []BenchmarkDiff        `json:"diffs"`
+		Metrics     []MetricComparisonView `json:"metrics"`
+		Winner      string                 `json:"winner"`
+		Significant bool                   `json:"significant"`
+		Summary     string                 `json:"summary"`
+	} `json:"comparison"`
+}
+
+// parseBenchmarkOptions reads the invoice query, iterations, warmup, order,
+// explain and stream from the query string. Streamed runs encode every row as
+// NDJSON and discard it
+func parseBenchmarkOptions(req *http.Request) (application.BenchmarkOptions, error) {
+	opts := application.BenchmarkOptions{Order: application.OrderRandom}
+
+	var err error
+	if opts.Query, err = parseInvoiceQuery(req); err != nil {
+		return opts, err
+	}
+	if opts.Iterations, err = parseBoundedInt(req, "iterations", defaultBenchmarkIterations, 1, maxBenchmarkIterations); err != nil {
+		return opts, err
+	}
+	if opts.Warmup, err = parseBoundedInt(req, "warmup", defaultBenchmarkWarmup, 0, maxBenchmarkWarmup); err != nil {
+		return opts, err
+	}
+	if v := req.URL.Query().Get("order"); v != "" {
+		if opts.Order, err = application.ParseBenchmarkOrder(v); err != nil {
+			return opts, err
+		}
+	}
+	if opts.Explain, err = parseBool(req, "explain"); err != nil {
+		return opts, err
+	}
+
+	stream, err := parseBool(req, "stream")
+	if err != nil {
+		return opts, err
+	}
+	if stream {
+		enc := json.NewEncoder(io.Discard)
+		opts.Stream = func(inv *invoices.Invoice) error {
+			return enc.Encode(toInvoiceView(inv))
+		}
+	}
+
+	return opts, nil
+}
+
+// payloadBytes returns a measure of the body of a run's listing response in
+// format before compression, as counted by the server's middleware
+func (r invoicesResource) payloadBytes(format responseFormat) application.MeasureFunc {
+	return func(result application.InvoicesResult) (float64, error) {
+		payload, _, err := r.measureResponse(result, format, cmd.EncodingIdentity)
+		return float64(payload), err
+	}
+}
+
+// wireBytes returns a measure of a run's JSON listing response compressed
+// with encoding, headers included, as counted by the server's middleware
+func (r invoicesResource) wireBytes(encoding string) application.MeasureFunc {
+	return func(result application.InvoicesResult) (float64, error) {
+		_, wire, err := r.measureResponse(result, responseFormats[0], encoding)
+		return float64(wire), err
+	}
+}
+
+// measureResponse serves the listing response GetInvoices would send for
+// result in format through the server's compression and byte counting
+// middleware, negotiating encoding, and returns the counted bytes
+func (r invoicesResource) measureResponse(result application.InvoicesResult, format responseFormat, encoding string) (payload, wire int64, err error) {
+	req, err := http.NewRequest(http.MethodGet, "/api/invoices/"+result.Strategy.Name, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Accept-Encoding", encoding)
+
+	var writeErr error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeErr = r.writeInvoices(w, format, result, nil)
+	})
+	payload, wire, err = cmd.MeasureResponse(handler, req)
+	if err != nil {
+		return 0, 0, err
+	}
+	if writeErr != nil {
+		return 0, 0, writeErr
+	}
+	return payload, wire, nil
+}
+
+// formatEncodeTime returns a measure of the time taken to encode a run's
+// invoices in format, including the conversion to views
+func formatEncodeTime(format responseFormat) application.MeasureFunc {
+	return func(result application.InvoicesResult) (float64, error) {
+		start := time.Now()
+		if err := encodeRows(format.newEncoder(io.Discard), result.Invoices); err != nil {
+			return 0, err
+		}
+		return application.DurationMs(time.Since(start)), nil
+	}
+}
+
+func (r invoicesResource) Benchmark(w http.ResponseWriter, req *http.Request) {
+	opts, err := parseBenchmarkOptions(req)
+	if err != nil {
+		common_http.ErrBadRequest(w, err)
+		return
+	}
+	opts.Measures = map[string]application.MeasureFunc{
+		metricWireBytes: r.wireBytes(cmd.EncodingIdentity),
+	}
+	for _, encoding := range cmd.Encodings {
+		if encoding != cmd.EncodingIdentity {
+			opts.Measures[metricWireBytes+"_"+encoding] = r.wireBytes(encoding)
+		}
+	}
+	for _, format := range responseFormats {
+		opts.Measures[metricEncodeTimeMs+"_"+format.name] = formatEncodeTime(format)
+		if format.name == formatJSON {
+			opts.Measures[metricPayloadBytes] = r.payloadBytes(format)
+		} else {
+			opts.Measures[metricPayloadBytes+"_"+format.name] = r.payloadBytes(format)
+		}
+	}
+
+	benchmark, err := r.service.RunBenchmark(req.Context(), opts)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	result := BenchmarkComparison{
+		Iterations: opts.Iterations,
+		Warmup:     opts.Warmup,
+		Order:      string(opts.Order),
+		Stream:     opts.Stream != nil,
+	}
+	for _, s := range benchmark.Strategies {
+		metrics := make(map[string]SummaryView, len(s.Metrics))
+		for name, summary := range s.Metrics {
+			metrics[name] = toSummaryView(summary)
+		}
+		result.Results = append(result.Results, BenchmarkMetrics{
+			Strategy:    s.Strategy.Name,
+			Computation: string(s.Strategy.Computation),
+			RowCount:    s.RowCount,
+			Metrics:     metrics,
+			Explain:     toExplainView(s.Plan),
+		})
+	}
+
+	if len(benchmark.Strategies) == 0 {
+		writeJSON(w, result)
+		return
+	}
+
+	mean := func(s application.StrategyBenchmark, metric string) float64 {
+		return s.Metrics[metric].Mean
+	}
+
+	// Baseline is the strategy with the lowest mean total time
+	baseline := benchmark.Strategies[0]
+	for _, s := range benchmark.Strategies[1:] {
+		if mean(s, application.MetricTotalTimeMs) < mean(baseline, application.MetricTotalTimeMs) {
+			baseline = s
+		}
+	}
+	result.Comparison.Baseline = baseline.Strategy.Name
+
+	// Calculate differences against the baseline
+	pct := func(diff, base float64) float64 {
+		if base > 0 {
+			return (diff / base) * 100
+		}
+		return 0
+	}
+	for _, s := range benchmark.Strategies {
+		diff := BenchmarkDiff{
+			Strategy:          s.Strategy.Name,
+			QueryTimeDiffMs:   mean(s, application.MetricQueryTimeMs) - mean(baseline, application.MetricQueryTimeMs),
+			TotalTimeDiffMs:   mean(s, application.MetricTotalTimeMs) - mean(baseline, application.MetricTotalTimeMs),
+			MemoryDiffBytes:   mean(s, application.MetricMemoryBytes) - mean(baseline, application.MetricMemoryBytes),
+			ResponseDiffBytes: mean(s, metricWireBytes) - mean(baseline, metricWireBytes),
+		}
+		diff.QueryTimeDiffPct = pct(diff.QueryTimeDiffMs, mean(baseline, application.MetricQueryTimeMs))
+		diff.TotalTimeDiffPct = pct(diff.TotalTimeDiffMs, mean(baseline, application.MetricTotalTimeMs))
+		diff.MemoryDiffPct = pct(diff.MemoryDiffBytes, mean(baseline, application.MetricMemoryBytes))
+		diff.ResponseDiffPct = pct(diff.ResponseDiffBytes, mean(baseline, metricWireBytes))
+
+		result.Comparison.Diffs = append(result.Comparison.Diffs, diff)
+	}
+
+	for _, c := range benchmark.Comparisons {
+		result.Comparison.Metrics = append(result.Comparison.Metrics, MetricComparisonView{
+			Metric:      c.Metric,
+			Best:        c.Best,
+			RunnerUp:    c.RunnerUp,
+			PValue:      c.PValue,
+			Significant: c.Significant,
+		})
+	}
+
+	result.Comparison.Winner = "inconclusive"
+	if benchmark.Winner != "" {
+		result.Comparison.Winner = benchmark.Winner
+		result.Comparison.Significant = true
+	}
+
+	summary := make([]string, 0, len(benchmark.Strategies)+1)
+	for _, s := range benchmark.Strategies {
+		total := s.Metrics[application.MetricTotalTimeMs]
+		summary = append(summary, fmt.Sprintf(
+			"%s: query=%.2fms, total=%.2fms (95%% CI %.2f-%.2f), mem=%.0fKB, response=%.0fKB",
+			s.Strategy.Name, mean(s, application.MetricQueryTimeMs), total.Mean, total.CILow, total.CIHigh,
+			mean(s, application.MetricMemoryBytes)/1024, mean(s, metricWireBytes)/1024,
+		))
+	}
+	summary = append(summary, "Winner: "+result.Comparison.Winner)
+	result.Comparison.Summary = strings.Join(summary, " | ")
+
+	writeJSON(w, result)
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	common_http "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/http"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// maxInvoiceRequestBytes limits the size of invoice request bodies
+const maxInvoiceRequestBytes = 1 << 20
+
+// InvoiceRequest is the body of create, replace and update requests.
+// Create and replace require every field, update only the changed ones
+type InvoiceRequest struct {
+	CustomerID  *int64            `json:"customer_id"`
+	AmountCents *int64            `json:"amount_cents"`
+	TaxRate     *invoices.TaxRate `json:"tax_rate"`
+}
+
+func (r InvoiceRequest) toInput() (invoices.InvoiceInput, error) {
+	if r.CustomerID == nil || r.AmountCents == nil || r.TaxRate == nil {
+		return invoices.InvoiceInput{}, fmt.Errorf("customer_id, amount_cents and tax_rate are required")
+	}
+	return invoices.InvoiceInput{
+		CustomerID:  *r.CustomerID,
+		AmountCents: *r.AmountCents,
+		TaxRate:     *r.TaxRate,
+	}, nil
+}
+
+func (r InvoiceRequest) toChanges() invoices.InvoiceChanges {
+	return invoices.InvoiceChanges{
+		CustomerID:  r.CustomerID,
+		AmountCents: r.AmountCents,
+		TaxRate:     r.TaxRate,
+	}
+}
+
+// decodeInvoiceRequest reads a JSON invoice request, rejecting unknown fields
+func decodeInvoiceRequest(w http.ResponseWriter, req *http.Request) (InvoiceRequest, error) {
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxInvoiceRequestBytes))
+	dec.DisallowUnknownFields()
+
+	var body InvoiceRequest
+	if err := dec.Decode(&body); err != nil {
+		return InvoiceRequest{}, fmt.Errorf("invalid request body: %w", err)
+	}
+	return body, nil
+}
+
+// InvoiceWriteResponse represents an invoice as written by the database
+type InvoiceWriteResponse struct {
+	Data        InvoiceView `json:"data"`
+	WriteTimeMs float64     `json:"write_time_ms"`
+}
+
+func toInvoiceWriteResponse(result application.WriteResult) InvoiceWriteResponse {
+	return InvoiceWriteResponse{
+		Data:        toInvoiceView(result.Invoice),
+		WriteTimeMs: application.DurationMs(result.Duration),
+	}
+}
+
+// CreateInvoice handles POST /api/invoices, writing the invoice to every
+// writable strategy's table
+func (r invoicesResource) CreateInvoice(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		common_http.ErrMethodNotAllowed(w, fmt.Errorf("method %s not allowed, use POST", req.Method))
+		return
+	}
+
+	body, err := decodeInvoiceRequest(w, req)
+	if err != nil {
+		common_http.ErrBadRequest(w, err)
+		return
+	}
+	input, err := body.toInput()
+	if err != nil {
+		common_http.ErrBadRequest(w, err)
+		return
+	}
+
+	result, err := r.service.CreateInvoice(req.Context(), input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, toInvoiceWriteResponse(result))
+}
+
+// ModifyInvoice handles PUT, PATCH and DELETE on /api/invoices/{id}. PUT
+// replaces every writable field, PATCH only the ones given
+func (r invoicesResource) ModifyInvoice(w http.ResponseWriter, req *http.Request) {
+	id, err := parseInvoiceID(strings.TrimPrefix(req.URL.Path, "/api/invoices/"))
+	if err != nil {
+		// Anything but an ID here is an unknown strategy
+		common_http.ErrNotFound(w, err)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPut, http.MethodPatch:
+		body, err := decodeInvoiceRequest(w, req)
+		if err != nil {
+			common_http.ErrBadRequest(w, err)
+			return
+		}
+
+		var result application.WriteResult
+		if req.Method == http.MethodPut {
+			var input invoices.InvoiceInput
+			input, err = body.toInput()
+			if err != nil {
+				common_http.ErrBadRequest(w, err)
+				return
+			}
+			result, err = r.service.ReplaceInvoice(req.Context(), id, input)
+		} else {
+			result, err = r.service.UpdateInvoice(req.Context(), id, body.toChanges())
+		}
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, toInvoiceWriteResponse(result))
+
+	case http.MethodDelete:
+		if err := r.service.DeleteInvoice(req.Context(), id); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		common_http.ErrMethodNotAllowed(w, fmt.Errorf("method %s not allowed, use PUT, PATCH or DELETE, or GET /api/invoices/{strategy}/{id}", req.Method))
+	}
+}
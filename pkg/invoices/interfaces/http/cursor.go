@@ -0,0 +1,139 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// errInvalidCursor is returned for cursors that are malformed, were signed
+// with another key or belong to another strategy
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorExclusiveParams cannot be combined with a cursor, which already
+// carries the filters and position of the listing
+var cursorExclusiveParams = []string{
+	"offset", "after_id", "customer_id",
+	"min_amount_cents", "max_amount_cents", "min_tax_rate", "max_tax_rate",
+}
+
+// cursorPayload is the signed content of a cursor
+type cursorPayload struct {
+	Strategy       string            `json:"s"`
+	AfterID        int64             `json:"a"`
+	Limit          int               `json:"l"`
+	CustomerID     *int64            `json:"c,omitempty"`
+	MinAmountCents *int64            `json:"amin,omitempty"`
+	MaxAmountCents *int64            `json:"amax,omitempty"`
+	MinTaxRate     *invoices.TaxRate `json:"tmin,omitempty"`
+	MaxTaxRate     *invoices.TaxRate `json:"tmax,omitempty"`
+}
+
+// CursorCodec encodes listing positions as opaque cursors signed with
+// HMAC-SHA256, so clients can't forge positions or filters
+type CursorCodec struct {
+	key []byte
+}
+
+// NewCursorCodec creates a CursorCodec signing with key. An empty key is
+// replaced with a random one, invalidating cursors on restart
+func NewCursorCodec(key []byte) (CursorCodec, error) {
+	if len(key) == 0 {
+		key = make([]byte, sha256.Size)
+		if _, err := rand.Read(key); err != nil {
+			return CursorCodec{}, fmt.Errorf("failed to generate cursor key: %w", err)
+		}
+	}
+	return CursorCodec{key: key}, nil
+}
+
+// Encode returns a cursor resuming the named strategy's listing at q
+func (c CursorCodec) Encode(strategy string, q invoices.Query) string {
+	payload, _ := json.Marshal(cursorPayload{
+		Strategy:       strategy,
+		AfterID:        int64(q.AfterID),
+		Limit:          q.Limit,
+		CustomerID:     q.CustomerID,
+		MinAmountCents: q.MinAmountCents,
+		MaxAmountCents: q.MaxAmountCents,
+		MinTaxRate:     q.MinTaxRate,
+		MaxTaxRate:     q.MaxTaxRate,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// Decode verifies a cursor issued for the named strategy and returns the
+// query it resumes
+func (c CursorCodec) Decode(strategy, cursor string) (invoices.Query, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return invoices.Query{}, errInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return invoices.Query{}, errInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return invoices.Query{}, errInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return invoices.Query{}, errInvalidCursor
+	}
+	if p.Strategy != strategy {
+		return invoices.Query{}, fmt.Errorf("%w: issued for strategy %s", errInvalidCursor, p.Strategy)
+	}
+
+	return invoices.Query{
+		Limit:          p.Limit,
+		AfterID:        invoices.ID(p.AfterID),
+		CustomerID:     p.CustomerID,
+		MinAmountCents: p.MinAmountCents,
+		MaxAmountCents: p.MaxAmountCents,
+		MinTaxRate:     p.MinTaxRate,
+		MaxTaxRate:     p.MaxTaxRate,
+	}, nil
+}
+
+func (c CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// parseListingQuery reads the invoice query for the named strategy's
+// listing, either from a cursor or from the query string. A limit given
+// alongside a cursor overrides the cursor's page size
+func (c CursorCodec) parseListingQuery(req *http.Request, strategy string) (invoices.Query, error) {
+	cursor := req.URL.Query().Get("cursor")
+	if cursor == "" {
+		return parseInvoiceQuery(req)
+	}
+
+	for _, name := range cursorExclusiveParams {
+		if req.URL.Query().Has(name) {
+			return invoices.Query{}, fmt.Errorf("%s cannot be combined with cursor", name)
+		}
+	}
+
+	q, err := c.Decode(strategy, cursor)
+	if err != nil {
+		return invoices.Query{}, err
+	}
+	if q.Limit, err = parseBoundedInt(req, "limit", q.Limit, 1, maxLimit); err != nil {
+		return invoices.Query{}, err
+	}
+
+	return q, q.Validate()
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+func newTestCursorCodec(t *testing.T, key string) CursorCodec {
+	t.Helper()
+	codec, err := NewCursorCodec([]byte(key))
+	if err != nil {
+		t.Fatalf("NewCursorCodec() error = %v", err)
+	}
+	return codec
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	codec := newTestCursorCodec(t, "secret")
+	customerID, minAmount, maxAmount := int64(7), int64(-500), int64(100000)
+	minRate, maxRate := invoices.NewTaxRate(5), invoices.NewTaxRate(19)
+
+	tests := []struct {
+		name  string
+		query invoices.Query
+	}{
+		{name: "position only", query: invoices.Query{Limit: 100, AfterID: 42}},
+		{
+			name: "all filters",
+			query: invoices.Query{
+				Limit:          25,
+				AfterID:        9000,
+				CustomerID:     &customerID,
+				MinAmountCents: &minAmount,
+				MaxAmountCents: &maxAmount,
+				MinTaxRate:     &minRate,
+				MaxTaxRate:     &maxRate,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := codec.Encode("virtual", tt.query)
+			got, err := codec.Decode("virtual", cursor)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.query) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.query)
+			}
+		})
+	}
+}
+
+func TestCursorRejectsTampering(t *testing.T) {
+	codec := newTestCursorCodec(t, "secret")
+	cursor := codec.Encode("virtual", invoices.Query{Limit: 100, AfterID: 42})
+	payload, signature, _ := strings.Cut(cursor, ".")
+
+	forged, _ := base64.RawURLEncoding.DecodeString(payload)
+	forged = []byte(strings.Replace(string(forged), `"a":42`, `"a":1`, 1))
+	flipped, _ := base64.RawURLEncoding.DecodeString(signature)
+	flipped[0] ^= 1
+
+	tests := []struct {
+		name   string
+		codec  CursorCodec
+		cursor string
+	}{
+		{name: "changed payload", codec: codec, cursor: base64.RawURLEncoding.EncodeToString(forged) + "." + signature},
+		{name: "changed signature", codec: codec, cursor: payload + "." + base64.RawURLEncoding.EncodeToString(flipped)},
+		{name: "missing signature", codec: codec, cursor: payload},
+		{name: "empty signature", codec: codec, cursor: payload + "."},
+		{name: "not base64", codec: codec, cursor: "!!." + signature},
+		{name: "other key", codec: newTestCursorCodec(t, "other"), cursor: cursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.codec.Decode("virtual", tt.cursor); !errors.Is(err, errInvalidCursor) {
+				t.Errorf("Decode() error = %v, want %v", err, errInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestCursorRejectsOtherStrategy(t *testing.T) {
+	codec := newTestCursorCodec(t, "secret")
+	cursor := codec.Encode("virtual", invoices.Query{Limit: 100, AfterID: 42})
+
+	if _, err := codec.Decode("trigger", cursor); !errors.Is(err, errInvalidCursor) {
+		t.Errorf("Decode() error = %v, want %v", err, errInvalidCursor)
+	}
+}
+
+func TestParseListingQueryResumesAfterPage(t *testing.T) {
+	codec := newTestCursorCodec(t, "secret")
+	customerID := int64(7)
+	first := invoices.Query{Limit: 2, Offset: 10, CustomerID: &customerID}
+	page := invoices.Page{NextAfterID: 42}
+	cursor := codec.Encode("virtual", first.Next(page))
+
+	tests := []struct {
+		name      string
+		params    string
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "cursor", params: "", wantLimit: 2},
+		{name: "limit override", params: "&limit=50", wantLimit: 50},
+		{name: "limit out of range", params: "&limit=0", wantErr: true},
+		{name: "with offset", params: "&offset=5", wantErr: true},
+		{name: "with after_id", params: "&after_id=1", wantErr: true},
+		{name: "with filter", params: "&customer_id=8", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/invoices/virtual?cursor="+cursor+tt.params, nil)
+			q, err := codec.parseListingQuery(req, "virtual")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseListingQuery() = %+v, want an error", q)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseListingQuery() error = %v", err)
+			}
+
+			// The next page starts after the last ID of the previous one, and
+			// the first page's offset is not applied again
+			if q.AfterID != 42 || q.Offset != 0 {
+				t.Errorf("parseListingQuery() resumes at after_id %d, offset %d, want 42, 0", q.AfterID, q.Offset)
+			}
+			if q.Limit != tt.wantLimit {
+				t.Errorf("parseListingQuery() limit = %d, want %d", q.Limit, tt.wantLimit)
+			}
+			if q.CustomerID == nil || *q.CustomerID != customerID {
+				t.Errorf("parseListingQuery() customer_id = %v, want %d", q.CustomerID, customerID)
+			}
+		})
+	}
+}
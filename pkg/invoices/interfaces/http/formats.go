@@ -0,0 +1,328 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// Response formats for invoice listings
+const (
+	formatJSON     = "json"
+	formatNDJSON   = "ndjson"
+	formatCSV      = "csv"
+	formatMsgpack  = "msgpack"
+	formatProtobuf = "protobuf"
+	formatArrow    = "arrow"
+)
+
+// responseFormat describes how invoice rows are encoded for one media type
+type responseFormat struct {
+	name        string
+	contentType string
+
+	// aliases are other media types selecting the format in Accept
+	aliases []string
+
+	newEncoder func(w io.Writer) rowEncoder
+
+	// flushRows overrides how many rows are streamed between flushes, for
+	// formats that write rows in batches. The first row is then not flushed
+	// on its own, so every streamed batch but the last holds flushRows rows
+	flushRows int
+}
+
+// rowEncoder writes a sequence of invoices in a response format
+type rowEncoder interface {
+	// begin writes anything preceding the first row
+	begin() error
+
+	// encode writes a single invoice
+	encode(inv *invoices.Invoice) error
+
+	// flush writes rows buffered by the encoder to the underlying writer
+	flush() error
+
+	// end writes anything following the last row and flushes
+	end() error
+}
+
+// responseFormats lists the supported formats. JSON comes first, as it is
+// used when the client expresses no preference
+var responseFormats = []responseFormat{
+	{name: formatJSON, contentType: "application/json", newEncoder: newJSONArrayEncoder},
+	{name: formatNDJSON, contentType: "application/x-ndjson", aliases: []string{"application/ndjson"}, newEncoder: newNDJSONEncoder},
+	{name: formatCSV, contentType: "text/csv", newEncoder: newCSVEncoder},
+	{name: formatMsgpack, contentType: "application/msgpack", aliases: []string{"application/x-msgpack"}, newEncoder: newMsgpackEncoder},
+	{name: formatProtobuf, contentType: "application/x-protobuf", aliases: []string{"application/protobuf"}, newEncoder: newProtobufEncoder},
+	{name: formatArrow, contentType: "application/vnd.apache.arrow.stream", newEncoder: newArrowEncoder, flushRows: arrowBatchRows},
+}
+
+// formatByName returns the supported format with the given name
+func formatByName(name string) (responseFormat, bool) {
+	for _, f := range responseFormats {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return responseFormat{}, false
+}
+
+// formatByMediaType returns the supported format serving the media type
+func formatByMediaType(mediaType string) (responseFormat, bool) {
+	for _, f := range responseFormats {
+		if f.contentType == mediaType {
+			return f, true
+		}
+		for _, alias := range f.aliases {
+			if alias == mediaType {
+				return f, true
+			}
+		}
+	}
+	return responseFormat{}, false
+}
+
+// errNotAcceptable is returned when the Accept header names no supported
+// media type
+var errNotAcceptable = errors.New("not acceptable")
+
+// negotiateFormat picks the response format from the format query parameter,
+// or else from the Accept header in order of preference. A missing header or
+// */* selects JSON, and a header matching no supported format returns
+// errNotAcceptable
+func negotiateFormat(req *http.Request) (responseFormat, error) {
+	if name := req.URL.Query().Get("format"); name != "" {
+		f, ok := formatByName(name)
+		if !ok {
+			return responseFormat{}, fmt.Errorf("unsupported format %q", name)
+		}
+		return f, nil
+	}
+
+	header := req.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return responseFormats[0], nil
+	}
+
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []accepted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		a := accepted{mediaType: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					a.q = q
+				}
+			}
+		}
+		if a.mediaType != "" && a.q > 0 {
+			candidates = append(candidates, a)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.mediaType == "*/*" {
+			return responseFormats[0], nil
+		}
+		if f, ok := formatByMediaType(c.mediaType); ok {
+			return f, nil
+		}
+	}
+
+	types := make([]string, len(responseFormats))
+	for i, f := range responseFormats {
+		types[i] = f.contentType
+	}
+	return responseFormat{}, fmt.Errorf("%w: %q matches none of %s", errNotAcceptable, header, strings.Join(types, ", "))
+}
+
+// encodeRows writes invs with enc from beginning to end
+func encodeRows(enc rowEncoder, invs []*invoices.Invoice) error {
+	if err := enc.begin(); err != nil {
+		return err
+	}
+	for _, inv := range invs {
+		if err := enc.encode(inv); err != nil {
+			return err
+		}
+	}
+	return enc.end()
+}
+
+// jsonArrayEncoder writes invoices as a JSON array
+type jsonArrayEncoder struct {
+	w    io.Writer
+	enc  *json.Encoder
+	rows int
+}
+
+func newJSONArrayEncoder(w io.Writer) rowEncoder {
+	return &jsonArrayEncoder{w: w, enc: json.NewEncoder(w)}
+}
+
+func (e *jsonArrayEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonArrayEncoder) encode(inv *invoices.Invoice) error {
+	if e.rows > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.rows++
+	return e.enc.Encode(toInvoiceView(inv))
+}
+
+func (e *jsonArrayEncoder) flush() error { return nil }
+
+func (e *jsonArrayEncoder) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// ndjsonEncoder writes one JSON invoice per line
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) rowEncoder {
+	return ndjsonEncoder{enc: json.NewEncoder(w)}
+}
+
+func (e ndjsonEncoder) begin() error                       { return nil }
+func (e ndjsonEncoder) encode(inv *invoices.Invoice) error { return e.enc.Encode(toInvoiceView(inv)) }
+func (e ndjsonEncoder) flush() error                       { return nil }
+func (e ndjsonEncoder) end() error                         { return nil }
+
+// csvHeader names the CSV columns, matching the JSON field names
+var csvHeader = []string{"id", "customer_id", "amount_cents", "tax_rate", "total_cents"}
+
+// csvEncoder writes invoices as CSV with a header row
+type csvEncoder struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVEncoder(w io.Writer) rowEncoder {
+	return &csvEncoder{w: csv.NewWriter(w), record: make([]string, len(csvHeader))}
+}
+
+func (e *csvEncoder) begin() error {
+	return e.w.Write(csvHeader)
+}
+
+func (e *csvEncoder) encode(inv *invoices.Invoice) error {
+	e.record[0] = strconv.FormatInt(int64(inv.ID()), 10)
+	e.record[1] = strconv.FormatInt(inv.CustomerID(), 10)
+	e.record[2] = strconv.FormatInt(inv.AmountCents(), 10)
+	e.record[3] = inv.TaxRate().String()
+	e.record[4] = strconv.FormatInt(inv.TotalCents(), 10)
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end() error {
+	return e.flush()
+}
+
+func init() {
+	// Tax rates are encoded as strings, like in JSON, rather than as the
+	// binary MessagePack makes of text marshalers
+	msgpack.Register(invoices.TaxRate{}, func(e *msgpack.Encoder, v reflect.Value) error {
+		return e.EncodeString(v.Interface().(invoices.TaxRate).String())
+	}, nil)
+}
+
+// msgpackEncoder writes invoices as a sequence of MessagePack maps keyed by
+// the JSON field names. A sequence rather than an array lets rows be
+// streamed before their count is known
+type msgpackEncoder struct {
+	enc *msgpack.Encoder
+}
+
+func newMsgpackEncoder(w io.Writer) rowEncoder {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	return msgpackEncoder{enc: enc}
+}
+
+func (e msgpackEncoder) begin() error                       { return nil }
+func (e msgpackEncoder) encode(inv *invoices.Invoice) error { return e.enc.Encode(toInvoiceView(inv)) }
+func (e msgpackEncoder) flush() error                       { return nil }
+func (e msgpackEncoder) end() error                         { return nil }
+
+// protobufEncoder writes invoices as a sequence of length-delimited Invoice
+// messages as defined in invoice.proto, the framing used by
+// writeDelimitedTo/parseDelimitedFrom in the protobuf libraries
+type protobufEncoder struct {
+	w   io.Writer
+	msg []byte
+	buf []byte
+}
+
+func newProtobufEncoder(w io.Writer) rowEncoder {
+	return &protobufEncoder{w: w}
+}
+
+func (e *protobufEncoder) begin() error { return nil }
+
+func (e *protobufEncoder) encode(inv *invoices.Invoice) error {
+	e.msg = appendInvoiceMessage(e.msg[:0], inv)
+	e.buf = protowire.AppendVarint(e.buf[:0], uint64(len(e.msg)))
+	e.buf = append(e.buf, e.msg...)
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+func (e *protobufEncoder) flush() error { return nil }
+func (e *protobufEncoder) end() error   { return nil }
+
+// appendInvoiceMessage appends the Invoice message encoding of inv to b.
+// Zero values are omitted, as proto3 does for scalar fields, except for the
+// exact tax rate, which is always set
+func appendInvoiceMessage(b []byte, inv *invoices.Invoice) []byte {
+	appendInt := func(b []byte, num protowire.Number, n int64) []byte {
+		if n == 0 {
+			return b
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(n))
+	}
+
+	b = appendInt(b, 1, int64(inv.ID()))
+	b = appendInt(b, 2, inv.CustomerID())
+	b = appendInt(b, 3, inv.AmountCents())
+	if rate := inv.TaxRate().Float64(); rate != 0 {
+		b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(rate))
+	}
+	b = appendInt(b, 5, inv.TotalCents())
+	b = protowire.AppendTag(b, 6, protowire.BytesType)
+	return protowire.AppendString(b, inv.TaxRate().String())
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		want   string
+		err    bool
+	}{
+		{name: "no header", want: formatJSON},
+		{name: "any type", accept: "*/*", want: formatJSON},
+		{name: "exact type", accept: "text/csv", want: formatCSV},
+		{name: "alias", accept: "application/x-msgpack", want: formatMsgpack},
+		{name: "preference", accept: "application/json;q=0.5, application/x-ndjson", want: formatNDJSON},
+		{name: "unsupported skipped", accept: "text/html, application/vnd.apache.arrow.stream;q=0.8", want: formatArrow},
+		{name: "any type after preferred", accept: "text/csv;q=0.9, */*;q=0.1", want: formatCSV},
+		{name: "query parameter", query: "format=protobuf", accept: "text/csv", want: formatProtobuf},
+		{name: "unsupported only", accept: "text/html", err: true},
+		{name: "refused only", accept: "application/json;q=0", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/invoices/virtual?"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			f, err := negotiateFormat(req)
+			if tt.err {
+				if !errors.Is(err, errNotAcceptable) {
+					t.Fatalf("negotiateFormat() error = %v, want %v", err, errNotAcceptable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("negotiateFormat() error = %v", err)
+			}
+			if f.name != tt.want {
+				t.Errorf("negotiateFormat() = %s, want %s", f.name, tt.want)
+			}
+		})
+	}
+}
@@ -2,256 +2,397 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	common_http "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/http"
 	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
 	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
 )
 
-const defaultLimit = 10000
+const (
+	defaultLimit = 10000
+	maxLimit     = 1000000
+)
 
-// AddRoutes registers invoice routes on the router
-func AddRoutes(mux *http.ServeMux, service application.InvoicesService) {
-	resource := invoicesResource{service: service}
+// AddRoutes registers invoice routes on the router.
+// Every registered strategy is served at /api/invoices/{strategy}, with
+// pagination cursors signed by cursors, and single invoices at
+// /api/invoices/{strategy}/{id}. Invoices are created at /api/invoices and
+// modified at /api/invoices/{id}, across all writable strategies
+func AddRoutes(mux *http.ServeMux, service application.InvoicesService, cursors CursorCodec) {
+	resource := invoicesResource{service: service, cursors: cursors}
 
-	mux.HandleFunc("/api/invoices/virtual", resource.GetWithVirtual)
-	mux.HandleFunc("/api/invoices/calculated", resource.GetWithCalculation)
+	for _, strategy := range service.Strategies() {
+		mux.HandleFunc("/api/invoices/"+strategy.Name, resource.GetInvoices(strategy.Name))
+		mux.HandleFunc("/api/invoices/"+strategy.Name+"/", resource.GetInvoice(strategy.Name))
+		if strategy.Refreshable {
+			mux.HandleFunc("/api/invoices/"+strategy.Name+"/refresh", resource.Refresh(strategy.Name))
+		}
+	}
+	mux.HandleFunc("/api/invoices", resource.CreateInvoice)
+	mux.HandleFunc("/api/invoices/", resource.ModifyInvoice)
+	mux.HandleFunc("/api/strategies", resource.GetStrategies)
 	mux.HandleFunc("/api/benchmark", resource.Benchmark)
+	mux.HandleFunc("/api/benchmark/write", resource.WriteBenchmark)
 	mux.HandleFunc("/api/stats", resource.GetStats)
+	mux.HandleFunc("/api/verify", resource.Verify)
 	mux.HandleFunc("/health", resource.HealthCheck)
 }
 
 type invoicesResource struct {
 	service application.InvoicesService
+	cursors CursorCodec
 }
 
 // InvoiceView represents an invoice in API responses
 type InvoiceView struct {
-	ID          int64   `json:"id"`
-	CustomerID  int64   `json:"customer_id"`
-	AmountCents int64   `json:"amount_cents"`
-	TaxRate     float64 `json:"tax_rate"`
-	TotalCents  int64   `json:"total_cents"`
+	ID          int64            `json:"id"`
+	CustomerID  int64            `json:"customer_id"`
+	AmountCents int64            `json:"amount_cents"`
+	TaxRate     invoices.TaxRate `json:"tax_rate"`
+	TotalCents  int64            `json:"total_cents"`
+}
+
+// QueryMetricsView represents the performance metrics of a fetch
+type QueryMetricsView struct {
+	QueryTimeMs  float64  `json:"query_time_ms"`
+	TotalTimeMs  float64  `json:"total_time_ms"`
+	CPUTimeNs    int64    `json:"cpu_time_ns"`
+	CPUUserNs    int64    `json:"cpu_user_ns"`
+	CPUSystemNs  int64    `json:"cpu_system_ns"`
+	DBBytesRecv  int64    `json:"db_bytes_received"`
+	DBBytesSent  int64    `json:"db_bytes_sent"`
+	DBExecTimeMs *float64 `json:"db_exec_time_ms,omitempty"`
+	DBCPUTimeMs  *float64 `json:"db_cpu_time_ms,omitempty"`
+	MemoryBytes  uint64   `json:"memory_bytes"`
+	AllocObjects uint64   `json:"alloc_objects"`
+	GCCycles     uint32   `json:"gc_cycles"`
+	GCPauseNs    int64    `json:"gc_pause_ns"`
+}
+
+func toQueryMetricsView(m invoices.QueryMetrics) QueryMetricsView {
+	view := QueryMetricsView{
+		QueryTimeMs:  m.QueryTimeMs(),
+		TotalTimeMs:  m.TotalTimeMs(),
+		CPUTimeNs:    m.CPUTimeNs(),
+		CPUUserNs:    m.CPUUser.Nanoseconds(),
+		CPUSystemNs:  m.CPUSystem.Nanoseconds(),
+		DBBytesRecv:  m.DBNetwork.Received,
+		DBBytesSent:  m.DBNetwork.Sent,
+		MemoryBytes:  m.MemoryBytes,
+		AllocObjects: m.AllocObjects,
+		GCCycles:     m.GCCycles,
+		GCPauseNs:    m.GCPause.Nanoseconds(),
+	}
+	if db := m.DBStats; db != nil {
+		execMs, cpuMs := application.DurationMs(db.ExecTime), application.DurationMs(db.CPUTime())
+		view.DBExecTimeMs = &execMs
+		view.DBCPUTimeMs = &cpuMs
+	}
+	return view
 }
 
 // InvoicesResponse wraps the API response with metrics
 type InvoicesResponse struct {
-	Data        []InvoiceView `json:"data"`
-	Count       int           `json:"count"`
-	QueryTimeMs float64       `json:"query_time_ms"`
-	TotalTimeMs float64       `json:"total_time_ms"`
-	CPUTimeNs   int64         `json:"cpu_time_ns"`
-	MemoryBytes uint64        `json:"memory_bytes"`
+	Strategy   string        `json:"strategy"`
+	Data       []InvoiceView `json:"data"`
+	Count      int           `json:"count"`
+	HasMore    bool          `json:"has_more"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	QueryMetricsView
+	Explain *ExplainView `json:"explain,omitempty"`
+}
+
+// InvoiceResponse wraps a single invoice with metrics
+type InvoiceResponse struct {
+	Strategy string      `json:"strategy"`
+	Data     InvoiceView `json:"data"`
+	QueryMetricsView
+}
+
+// ExplainView represents the executed plan of a fetch query
+type ExplainView struct {
+	PlanningTimeMs   float64         `json:"planning_time_ms"`
+	ExecutionTimeMs  float64         `json:"execution_time_ms"`
+	SharedHitBlocks  int64           `json:"shared_hit_blocks"`
+	SharedReadBlocks int64           `json:"shared_read_blocks"`
+	Plan             json.RawMessage `json:"plan"`
+}
+
+func toExplainView(plan *invoices.QueryPlan) *ExplainView {
+	if plan == nil {
+		return nil
+	}
+	return &ExplainView{
+		PlanningTimeMs:   application.DurationMs(plan.PlanningTime),
+		ExecutionTimeMs:  application.DurationMs(plan.ExecutionTime),
+		SharedHitBlocks:  plan.SharedHitBlocks,
+		SharedReadBlocks: plan.SharedReadBlocks,
+		Plan:             json.RawMessage(plan.Plan),
+	}
+}
+
+func toInvoiceView(inv *invoices.Invoice) InvoiceView {
+	return InvoiceView{
+		ID:          int64(inv.ID()),
+		CustomerID:  inv.CustomerID(),
+		AmountCents: inv.AmountCents(),
+		TaxRate:     inv.TaxRate(),
+		TotalCents:  inv.TotalCents(),
+	}
 }
 
 func toInvoiceViews(invs []*invoices.Invoice) []InvoiceView {
 	views := make([]InvoiceView, len(invs))
 	for i, inv := range invs {
-		views[i] = InvoiceView{
-			ID:          int64(inv.ID()),
-			CustomerID:  inv.CustomerID(),
-			AmountCents: inv.AmountCents(),
-			TaxRate:     inv.TaxRate(),
-			TotalCents:  inv.TotalCents(),
-		}
+		views[i] = toInvoiceView(inv)
 	}
 	return views
 }
 
 func writeJSON(w http.ResponseWriter, data any) {
+	writeJSONStatus(w, http.StatusOK, data)
+}
+
+func writeJSONStatus(w http.ResponseWriter, statusCode int, data any) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
 }
 
-func (r invoicesResource) GetWithVirtual(w http.ResponseWriter, req *http.Request) {
-	result, err := r.service.GetInvoicesWithVirtual(req.Context(), defaultLimit)
-	if err != nil {
-		common_http.ErrInternal(w, err)
+// writeServiceError maps service errors to HTTP error responses
+func writeServiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, invoices.ErrStrategyNotFound) || errors.Is(err, invoices.ErrInvoiceNotFound) {
+		common_http.ErrNotFound(w, err)
+		return
+	}
+	if errors.Is(err, invoices.ErrStrategyNotRefreshable) || errors.Is(err, invoices.ErrStrategyNotWritable) ||
+		errors.Is(err, invoices.ErrInvalidQuery) || errors.Is(err, invoices.ErrInvalidInvoice) ||
+		errors.Is(err, invoices.ErrInvalidComparison) {
+		common_http.ErrBadRequest(w, err)
 		return
 	}
+	common_http.ErrInternal(w, err)
+}
 
-	writeJSON(w, InvoicesResponse{
-		Data:        toInvoiceViews(result.Invoices),
-		Count:       len(result.Invoices),
-		QueryTimeMs: result.Metrics.QueryTimeMs(),
-		TotalTimeMs: result.Metrics.TotalTimeMs(),
-		CPUTimeNs:   result.Metrics.CPUTimeNs(),
-		MemoryBytes: result.Metrics.MemoryBytes,
-	})
+// GetInvoices returns a handler serving invoices fetched with the named
+// strategy, filtered and paged by the query string or a cursor. JSON
+// responses wrap the invoices with their metrics, while other formats only
+// carry rows. With stream=true the invoices are streamed as they are read
+func (r invoicesResource) GetInvoices(strategy string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query, err := r.cursors.parseListingQuery(req, strategy)
+		if err != nil {
+			common_http.ErrBadRequest(w, err)
+			return
+		}
+		stream, err := parseBool(req, "stream")
+		if err != nil {
+			common_http.ErrBadRequest(w, err)
+			return
+		}
+		format, err := negotiateFormat(req)
+		if errors.Is(err, errNotAcceptable) {
+			common_http.ErrNotAcceptable(w, err)
+			return
+		}
+		if err != nil {
+			common_http.ErrBadRequest(w, err)
+			return
+		}
+		explain, err := parseBool(req, "explain")
+		if err != nil {
+			common_http.ErrBadRequest(w, err)
+			return
+		}
+		if explain && stream {
+			common_http.ErrBadRequest(w, fmt.Errorf("explain is not available in streamed responses"))
+			return
+		}
+		if stream {
+			r.streamInvoices(w, req, strategy, query, format)
+			return
+		}
+		if explain && format.name != formatJSON {
+			common_http.ErrBadRequest(w, fmt.Errorf("explain is only available in JSON responses"))
+			return
+		}
+
+		result, err := r.service.GetInvoices(req.Context(), strategy, query)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		var plan *invoices.QueryPlan
+		if explain {
+			explained, err := r.service.ExplainInvoices(req.Context(), strategy, query)
+			if err != nil {
+				writeServiceError(w, err)
+				return
+			}
+			plan = &explained
+		}
+
+		if err := r.writeInvoices(w, format, result, plan); err != nil {
+			log.Printf("failed to write %s invoices: %v", format.name, err)
+		}
+	}
 }
 
-func (r invoicesResource) GetWithCalculation(w http.ResponseWriter, req *http.Request) {
-	result, err := r.service.GetInvoicesWithCalculation(req.Context(), defaultLimit)
-	if err != nil {
-		common_http.ErrInternal(w, err)
-		return
+// writeInvoices writes a fetched page of invoices in format, with a cursor to
+// the next page. JSON responses wrap the invoices with their metrics and
+// plan, while other formats carry the metrics in headers
+func (r invoicesResource) writeInvoices(w http.ResponseWriter, format responseFormat, result application.InvoicesResult, plan *invoices.QueryPlan) error {
+	var nextCursor string
+	if result.HasMore {
+		nextCursor = r.cursors.Encode(result.Strategy.Name, result.Next)
+	}
+	if format.name != formatJSON {
+		return writeRows(w, format, result, nextCursor)
 	}
 
-	writeJSON(w, InvoicesResponse{
-		Data:        toInvoiceViews(result.Invoices),
-		Count:       len(result.Invoices),
-		QueryTimeMs: result.Metrics.QueryTimeMs(),
-		TotalTimeMs: result.Metrics.TotalTimeMs(),
-		CPUTimeNs:   result.Metrics.CPUTimeNs(),
-		MemoryBytes: result.Metrics.MemoryBytes,
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(InvoicesResponse{
+		Strategy:         result.Strategy.Name,
+		Data:             toInvoiceViews(result.Invoices),
+		Count:            len(result.Invoices),
+		HasMore:          result.HasMore,
+		NextCursor:       nextCursor,
+		QueryMetricsView: toQueryMetricsView(result.Metrics),
+		Explain:          toExplainView(plan),
 	})
 }
 
-// StatsResponse represents table statistics
-type StatsResponse struct {
-	InvoicesWithVirtualCount    int64 `json:"invoices_with_virtual_count"`
-	InvoicesWithoutVirtualCount int64 `json:"invoices_without_virtual_count"`
-}
+// GetInvoice returns a handler serving a single invoice fetched with the named
+// strategy, identified by the last path segment
+func (r invoicesResource) GetInvoice(strategy string) http.HandlerFunc {
+	prefix := "/api/invoices/" + strategy + "/"
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			common_http.ErrMethodNotAllowed(w, fmt.Errorf("method %s not allowed, use GET", req.Method))
+			return
+		}
 
-func (r invoicesResource) GetStats(w http.ResponseWriter, req *http.Request) {
-	stats, err := r.service.GetStats(req.Context())
-	if err != nil {
-		common_http.ErrInternal(w, err)
-		return
-	}
+		id, err := parseInvoiceID(strings.TrimPrefix(req.URL.Path, prefix))
+		if err != nil {
+			common_http.ErrBadRequest(w, err)
+			return
+		}
 
-	writeJSON(w, StatsResponse{
-		InvoicesWithVirtualCount:    stats.WithVirtualCount,
-		InvoicesWithoutVirtualCount: stats.WithoutVirtualCount,
-	})
+		result, err := r.service.GetInvoice(req.Context(), strategy, id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, InvoiceResponse{
+			Strategy:         result.Strategy.Name,
+			Data:             toInvoiceView(result.Invoice),
+			QueryMetricsView: toQueryMetricsView(result.Metrics),
+		})
+	}
 }
 
-// HealthResponse represents health check response
-type HealthResponse struct {
-	Status string `json:"status"`
+// RefreshResponse represents the outcome of refreshing a strategy
+type RefreshResponse struct {
+	Strategy      string  `json:"strategy"`
+	RefreshTimeMs float64 `json:"refresh_time_ms"`
 }
 
-func (r invoicesResource) HealthCheck(w http.ResponseWriter, req *http.Request) {
-	writeJSON(w, HealthResponse{Status: "ok"})
+// Refresh returns a handler recomputing the precomputed data of the named strategy
+func (r invoicesResource) Refresh(strategy string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			common_http.ErrMethodNotAllowed(w, fmt.Errorf("method %s not allowed, use POST", req.Method))
+			return
+		}
+
+		result, err := r.service.RefreshStrategy(req.Context(), strategy)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, RefreshResponse{
+			Strategy:      result.Strategy.Name,
+			RefreshTimeMs: application.DurationMs(result.Duration),
+		})
+	}
 }
 
-// BenchmarkMetrics holds metrics for a single test run
-type BenchmarkMetrics struct {
-	QueryTimeMs   float64 `json:"query_time_ms"`
-	TotalTimeMs   float64 `json:"total_time_ms"`
-	CPUTimeNs     int64   `json:"cpu_time_ns"`
-	MemoryBytes   uint64  `json:"memory_bytes"`
-	RowCount      int     `json:"row_count"`
-	ResponseBytes int     `json:"response_bytes"`
+// StrategyView represents a total computation strategy in API responses
+type StrategyView struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Computation string `json:"computation"`
+	Rounding    string `json:"rounding,omitempty"`
+	Endpoint    string `json:"endpoint"`
+	Refreshable bool   `json:"refreshable"`
 }
 
-// BenchmarkComparison compares virtual vs calculated approaches
-type BenchmarkComparison struct {
-	Virtual    BenchmarkMetrics `json:"virtual"`
-	Calculated BenchmarkMetrics `json:"calculated"`
-	Comparison struct {
-		QueryTimeDiffMs   float64 `json:"query_time_diff_ms"`
-		QueryTimeDiffPct  float64 `json:"query_time_diff_pct"`
-		TotalTimeDiffMs   float64 `json:"total_time_diff_ms"`
-		TotalTimeDiffPct  float64 `json:"total_time_diff_pct"`
-		MemoryDiffBytes   int64   `json:"memory_diff_bytes"`
-		MemoryDiffPct     float64 `json:"memory_diff_pct"`
-		ResponseDiffBytes int     `json:"response_diff_bytes"`
-		ResponseDiffPct   float64 `json:"response_diff_pct"`
-		Winner            string  `json:"winner"`
-		Summary           string  `json:"summary"`
-	} `json:"comparison"`
+func toStrategyView(s invoices.Strategy) StrategyView {
+	return StrategyView{
+		Name:        s.Name,
+		Description: s.Description,
+		Computation: string(s.Computation),
+		Rounding:    string(s.Rounding),
+		Endpoint:    "/api/invoices/" + s.Name,
+		Refreshable: s.Refreshable,
+	}
 }
 
-func (r invoicesResource) Benchmark(w http.ResponseWriter, req *http.Request) {
-	// Run virtual column test
-	virtualResult, err := r.service.GetInvoicesWithVirtual(req.Context(), defaultLimit)
-	if err != nil {
-		common_http.ErrInternal(w, err)
-		return
+func (r invoicesResource) GetStrategies(w http.ResponseWriter, req *http.Request) {
+	strategies := r.service.Strategies()
+	views := make([]StrategyView, len(strategies))
+	for i, s := range strategies {
+		views[i] = toStrategyView(s)
 	}
-	virtualViews := toInvoiceViews(virtualResult.Invoices)
-	virtualJSON, _ := json.Marshal(virtualViews)
 
-	// Run calculated test
-	calcResult, err := r.service.GetInvoicesWithCalculation(req.Context(), defaultLimit)
+	writeJSON(w, views)
+}
+
+// StrategyStatsView represents statistics for a single strategy
+type StrategyStatsView struct {
+	Strategy    string `json:"strategy"`
+	Computation string `json:"computation"`
+	Count       int64  `json:"count"`
+}
+
+// StatsResponse represents table statistics
+type StatsResponse struct {
+	Strategies []StrategyStatsView `json:"strategies"`
+}
+
+func (r invoicesResource) GetStats(w http.ResponseWriter, req *http.Request) {
+	stats, err := r.service.GetStats(req.Context())
 	if err != nil {
 		common_http.ErrInternal(w, err)
 		return
 	}
-	calcViews := toInvoiceViews(calcResult.Invoices)
-	calcJSON, _ := json.Marshal(calcViews)
-
-	// Build comparison
-	result := BenchmarkComparison{
-		Virtual: BenchmarkMetrics{
-			QueryTimeMs:   virtualResult.Metrics.QueryTimeMs(),
-			TotalTimeMs:   virtualResult.Metrics.TotalTimeMs(),
-			CPUTimeNs:     virtualResult.Metrics.CPUTimeNs(),
-			MemoryBytes:   virtualResult.Metrics.MemoryBytes,
-			RowCount:      len(virtualResult.Invoices),
-			ResponseBytes: len(virtualJSON),
-		},
-		Calculated: BenchmarkMetrics{
-			QueryTimeMs:   calcResult.Metrics.QueryTimeMs(),
-			TotalTimeMs:   calcResult.Metrics.TotalTimeMs(),
-			CPUTimeNs:     calcResult.Metrics.CPUTimeNs(),
-			MemoryBytes:   calcResult.Metrics.MemoryBytes,
-			RowCount:      len(calcResult.Invoices),
-			ResponseBytes: len(calcJSON),
-		},
-	}
-
-	// Calculate differences (positive = virtual is better)
-	result.Comparison.QueryTimeDiffMs = calcResult.Metrics.QueryTimeMs() - virtualResult.Metrics.QueryTimeMs()
-	result.Comparison.TotalTimeDiffMs = calcResult.Metrics.TotalTimeMs() - virtualResult.Metrics.TotalTimeMs()
-	result.Comparison.MemoryDiffBytes = int64(calcResult.Metrics.MemoryBytes) - int64(virtualResult.Metrics.MemoryBytes)
-	result.Comparison.ResponseDiffBytes = len(calcJSON) - len(virtualJSON)
 
-	// Calculate percentages
-	if virtualResult.Metrics.QueryTimeMs() > 0 {
-		result.Comparison.QueryTimeDiffPct = (result.Comparison.QueryTimeDiffMs / virtualResult.Metrics.QueryTimeMs()) * 100
-	}
-	if virtualResult.Metrics.TotalTimeMs() > 0 {
-		result.Comparison.TotalTimeDiffPct = (result.Comparison.TotalTimeDiffMs / virtualResult.Metrics.TotalTimeMs()) * 100
-	}
-	if virtualResult.Metrics.MemoryBytes > 0 {
-		result.Comparison.MemoryDiffPct = (float64(result.Comparison.MemoryDiffBytes) / float64(virtualResult.Metrics.MemoryBytes)) * 100
-	}
-	if len(virtualJSON) > 0 {
-		result.Comparison.ResponseDiffPct = (float64(result.Comparison.ResponseDiffBytes) / float64(len(virtualJSON))) * 100
+	response := StatsResponse{Strategies: make([]StrategyStatsView, len(stats.Strategies))}
+	for i, s := range stats.Strategies {
+		response.Strategies[i] = StrategyStatsView{
+			Strategy:    s.Strategy.Name,
+			Computation: string(s.Strategy.Computation),
+			Count:       s.Count,
+		}
 	}
 
-	// Determine winner
-	virtualScore := 0
-	calcScore := 0
-
-	if result.Comparison.QueryTimeDiffMs > 0 {
-		virtualScore++
-	} else {
-		calcScore++
-	}
-	if result.Comparison.TotalTimeDiffMs > 0 {
-		virtualScore++
-	} else {
-		calcScore++
-	}
-	if result.Comparison.MemoryDiffBytes > 0 {
-		virtualScore++
-	} else {
-		calcScore++
-	}
+	writeJSON(w, response)
+}
 
-	if virtualScore > calcScore {
-		result.Comparison.Winner = "virtual"
-	} else if calcScore > virtualScore {
-		result.Comparison.Winner = "calculated"
-	} else {
-		result.Comparison.Winner = "tie"
-	}
+// HealthResponse represents health check response
+type HealthResponse struct {
+	Status string `json:"status"`
+}
 
-	result.Comparison.Summary = fmt.Sprintf(
-		"Virtual: query=%.2fms, total=%.2fms, mem=%dKB, response=%dKB | "+
-			"Calculated: query=%.2fms, total=%.2fms, mem=%dKB, response=%dKB | "+
-			"Winner: %s",
-		result.Virtual.QueryTimeMs, result.Virtual.TotalTimeMs,
-		result.Virtual.MemoryBytes/1024, result.Virtual.ResponseBytes/1024,
-		result.Calculated.QueryTimeMs, result.Calculated.TotalTimeMs,
-		result.Calculated.MemoryBytes/1024, result.Calculated.ResponseBytes/1024,
-		result.Comparison.Winner,
-	)
-
-	writeJSON(w, result)
+func (r invoicesResource) HealthCheck(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, HealthResponse{Status: "ok"})
 }
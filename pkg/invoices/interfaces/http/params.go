@@ -0,0 +1,120 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// parseBoundedInt parses an optional integer query parameter within [min, max]
+func parseBoundedInt(req *http.Request, name string, def, min, max int) (int, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", name, min, max)
+	}
+	return n, nil
+}
+
+// parseBool parses an optional boolean query parameter, defaulting to false
+func parseBool(req *http.Request, name string) (bool, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean", name)
+	}
+	return b, nil
+}
+
+// parseOptionalInt64 parses an optional integer query parameter, returning
+// nil when it is absent
+func parseOptionalInt64(req *http.Request, name string) (*int64, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+	return &n, nil
+}
+
+// parseOptionalTaxRate parses an optional exact tax rate query parameter,
+// returning nil when it is absent
+func parseOptionalTaxRate(req *http.Request, name string) (*invoices.TaxRate, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	rate, err := invoices.ParseTaxRate(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a decimal with at most 2 decimal places", name)
+	}
+	return &rate, nil
+}
+
+// parseInvoiceQuery reads limit, offset, after_id, customer_id and the
+// amount and tax rate ranges from the query string
+func parseInvoiceQuery(req *http.Request) (invoices.Query, error) {
+	var q invoices.Query
+	var err error
+
+	if q.Limit, err = parseBoundedInt(req, "limit", defaultLimit, 1, maxLimit); err != nil {
+		return q, err
+	}
+	if q.Offset, err = parseBoundedInt(req, "offset", 0, 0, math.MaxInt32); err != nil {
+		return q, err
+	}
+
+	afterID, err := parseOptionalInt64(req, "after_id")
+	if err != nil {
+		return q, err
+	}
+	if afterID != nil {
+		if *afterID < 0 {
+			return q, fmt.Errorf("after_id must not be negative")
+		}
+		q.AfterID = invoices.ID(*afterID)
+	}
+
+	if q.CustomerID, err = parseOptionalInt64(req, "customer_id"); err != nil {
+		return q, err
+	}
+	if q.MinAmountCents, err = parseOptionalInt64(req, "min_amount_cents"); err != nil {
+		return q, err
+	}
+	if q.MaxAmountCents, err = parseOptionalInt64(req, "max_amount_cents"); err != nil {
+		return q, err
+	}
+	if q.MinTaxRate, err = parseOptionalTaxRate(req, "min_tax_rate"); err != nil {
+		return q, err
+	}
+	if q.MaxTaxRate, err = parseOptionalTaxRate(req, "max_tax_rate"); err != nil {
+		return q, err
+	}
+
+	return q, q.Validate()
+}
+
+// parseInvoiceID parses an invoice ID path segment
+func parseInvoiceID(segment string) (invoices.ID, error) {
+	id, err := strconv.ParseInt(segment, 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invoice id must be a positive integer, got %q", segment)
+	}
+	return invoices.ID(id), nil
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// streamFlushRows is how many rows are written between flushes after the
+// first row, which is flushed on its own to minimise time to first byte.
+// Formats setting their own interval write batches of uniform size instead
+const streamFlushRows = 1000
+
+// Headers carrying listing metrics for row formats. Streamed responses send
+// them as trailers, once the metrics are known
+const (
+	rowCountHeader    = "X-Row-Count"
+	queryTimeHeader   = "X-Query-Time-Ms"
+	totalTimeHeader   = "X-Total-Time-Ms"
+	firstByteHeader   = "X-Time-To-First-Byte-Ms"
+	nextCursorHeader  = "X-Next-Cursor"
+	streamErrorHeader = "X-Stream-Error"
+)
+
+// rowWriter encodes streamed invoices in a response format. The response
+// starts with the first row, so errors before it can still be reported with
+// a regular error response
+type rowWriter struct {
+	w       http.ResponseWriter
+	format  responseFormat
+	enc     rowEncoder
+	start   time.Time
+	started bool
+	rows    int
+
+	// firstByte is the time from start until the first rows were flushed
+	firstByte time.Duration
+}
+
+func newRowWriter(w http.ResponseWriter, format responseFormat) *rowWriter {
+	return &rowWriter{w: w, format: format, enc: format.newEncoder(w), start: time.Now()}
+}
+
+// begin declares the trailers and writes the response header
+func (rw *rowWriter) begin() error {
+	rw.started = true
+
+	header := rw.w.Header()
+	for _, trailer := range []string{rowCountHeader, queryTimeHeader, firstByteHeader, streamErrorHeader} {
+		header.Add("Trailer", trailer)
+	}
+	header.Set("Content-Type", rw.format.contentType)
+	rw.w.WriteHeader(http.StatusOK)
+	return rw.enc.begin()
+}
+
+// write encodes a single invoice, flushing the first row immediately and
+// every streamFlushRows rows after it, or every flushRows rows of the format
+func (rw *rowWriter) write(inv *invoices.Invoice) error {
+	if !rw.started {
+		if err := rw.begin(); err != nil {
+			return err
+		}
+	}
+	if err := rw.enc.encode(inv); err != nil {
+		return err
+	}
+
+	flushRows, flushFirst := rw.format.flushRows, false
+	if flushRows == 0 {
+		flushRows, flushFirst = streamFlushRows, true
+	}
+
+	rw.rows++
+	if (flushFirst && rw.rows == 1) || rw.rows%flushRows == 0 {
+		if err := rw.flush(); err != nil {
+			return err
+		}
+		if rw.firstByte == 0 {
+			rw.firstByte = time.Since(rw.start)
+		}
+	}
+	return nil
+}
+
+// end finishes the encoding and flushes the remaining rows
+func (rw *rowWriter) end() error {
+	if !rw.started {
+		if err := rw.begin(); err != nil {
+			return err
+		}
+	}
+	if err := rw.enc.end(); err != nil {
+		return err
+	}
+	if err := rw.flush(); err != nil {
+		return err
+	}
+
+	// Nothing was flushed for an empty result, or one shorter than a batch,
+	// until now
+	if rw.firstByte == 0 {
+		rw.firstByte = time.Since(rw.start)
+	}
+	return nil
+}
+
+func (rw *rowWriter) flush() error {
+	if err := rw.enc.flush(); err != nil {
+		return err
+	}
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// streamInvoices writes the invoices selected by query in format as they are
+// read from the database. Metrics are sent in trailers, as they are only
+// known at the end
+func (r invoicesResource) streamInvoices(w http.ResponseWriter, req *http.Request, strategy string, query invoices.Query, format responseFormat) {
+	rw := newRowWriter(w, format)
+
+	result, err := r.service.StreamInvoices(req.Context(), strategy, query, rw.write)
+	if err == nil {
+		err = rw.end()
+	}
+	if err != nil {
+		if !rw.started {
+			writeServiceError(w, err)
+			return
+		}
+		// The status has been sent, so the error can only be reported in a
+		// trailer. JSON arrays are left unterminated to signal truncation
+		log.Printf("failed to stream %s invoices after %d rows: %v", strategy, rw.rows, err)
+		w.Header().Set(streamErrorHeader, err.Error())
+		w.Header().Set(rowCountHeader, strconv.Itoa(rw.rows))
+		return
+	}
+
+	w.Header().Set(rowCountHeader, strconv.Itoa(result.Rows))
+	w.Header().Set(queryTimeHeader, formatMs(result.Metrics.QueryTimeMs()))
+	w.Header().Set(firstByteHeader, formatMs(application.DurationMs(rw.firstByte)))
+}
+
+// writeRows writes a buffered listing in a row format, with its metrics in
+// headers since there is no envelope to carry them
+func writeRows(w http.ResponseWriter, format responseFormat, result application.InvoicesResult, nextCursor string) error {
+	header := w.Header()
+	header.Set("Content-Type", format.contentType)
+	header.Set(rowCountHeader, strconv.Itoa(len(result.Invoices)))
+	header.Set(queryTimeHeader, formatMs(result.Metrics.QueryTimeMs()))
+	header.Set(totalTimeHeader, formatMs(result.Metrics.TotalTimeMs()))
+	if nextCursor != "" {
+		header.Set(nextCursorHeader, nextCursor)
+	}
+
+	return encodeRows(format.newEncoder(w), result.Invoices)
+}
+
+// formatMs formats milliseconds for a header
+func formatMs(ms float64) string {
+	return strconv.FormatFloat(ms, 'f', 3, 64)
+}
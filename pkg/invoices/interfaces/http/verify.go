@@ -0,0 +1,100 @@
+package http
+
+import (
+	"net/http"
+
+	common_http "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/http"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/domain/invoices"
+)
+
+// Strategies compared by /api/verify when none are given: the STORED
+// generated column against the same inputs calculated in Go
+const (
+	defaultVerifyDatabase    = "virtual"
+	defaultVerifyApplication = "calculated"
+)
+
+// Mismatches listed by /api/verify. All of them are counted, but a
+// systematic disagreement over a large table would not fit in a response
+const (
+	defaultVerifyLimit = 100
+	maxVerifyLimit     = 10000
+)
+
+// TotalMismatchView represents an invoice whose totals differ
+type TotalMismatchView struct {
+	ID                    int64            `json:"id"`
+	AmountCents           int64            `json:"amount_cents"`
+	TaxRate               invoices.TaxRate `json:"tax_rate"`
+	DatabaseTotalCents    int64            `json:"database_total_cents"`
+	ApplicationTotalCents int64            `json:"application_total_cents"`
+}
+
+// VerifyResponse represents the comparison of database and Go totals
+type VerifyResponse struct {
+	DatabaseStrategy    string              `json:"database_strategy"`
+	ApplicationStrategy string              `json:"application_strategy"`
+	Equivalent          bool                `json:"equivalent"`
+	RowsCompared        int64               `json:"rows_compared"`
+	RowsUnmatched       int64               `json:"rows_unmatched"`
+	MismatchCount       int64               `json:"mismatch_count"`
+	Mismatches          []TotalMismatchView `json:"mismatches"`
+	MismatchesTruncated bool                `json:"mismatches_truncated"`
+	DurationMs          float64             `json:"duration_ms"`
+}
+
+// Verify compares every total computed by a database strategy with the total
+// computed in Go for the same row, selected with the database and application
+// query parameters. Every mismatch is counted, and the first limit are listed
+func (r invoicesResource) Verify(w http.ResponseWriter, req *http.Request) {
+	database := req.URL.Query().Get("database")
+	if database == "" {
+		database = defaultVerifyDatabase
+	}
+	application := req.URL.Query().Get("application")
+	if application == "" {
+		application = defaultVerifyApplication
+	}
+
+	limit, err := parseBoundedInt(req, "limit", defaultVerifyLimit, 0, maxVerifyLimit)
+	if err != nil {
+		common_http.ErrBadRequest(w, err)
+		return
+	}
+
+	mismatches := []TotalMismatchView{}
+	result, err := r.service.VerifyTotals(req.Context(), database, application, func(m invoices.TotalMismatch) error {
+		if len(mismatches) >= limit {
+			return nil
+		}
+		mismatches = append(mismatches, TotalMismatchView{
+			ID:                    int64(m.ID),
+			AmountCents:           m.AmountCents,
+			TaxRate:               m.TaxRate,
+			DatabaseTotalCents:    m.DatabaseTotalCents,
+			ApplicationTotalCents: m.ApplicationTotalCents,
+		})
+		return nil
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, toVerifyResponse(result, mismatches))
+}
+
+func toVerifyResponse(result application.TotalsVerification, mismatches []TotalMismatchView) VerifyResponse {
+	return VerifyResponse{
+		DatabaseStrategy:    result.Database.Name,
+		ApplicationStrategy: result.Application.Name,
+		Equivalent:          result.Equivalent(),
+		RowsCompared:        result.RowsCompared,
+		RowsUnmatched:       result.RowsUnmatched,
+		MismatchCount:       result.Mismatches,
+		Mismatches:          mismatches,
+		MismatchesTruncated: result.Mismatches > int64(len(mismatches)),
+		DurationMs:          application.DurationMs(result.Duration),
+	}
+}
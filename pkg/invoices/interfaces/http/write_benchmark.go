@@ -0,0 +1,105 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	common_http "github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/common/http"
+	"github.com/KArjmand/go_postgres_virtual_generated_column_test/pkg/invoices/application"
+)
+
+const (
+	defaultWriteBenchmarkRows = 1000
+	maxWriteBenchmarkRows     = 100000
+)
+
+// LatencyView represents a latency summary in milliseconds
+type LatencyView struct {
+	MinMs  float64 `json:"min_ms"`
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// WritePhaseView represents metrics for one write operation type
+type WritePhaseView struct {
+	Rows             int         `json:"rows"`
+	TotalTimeMs      float64     `json:"total_time_ms"`
+	RowsPerSec       float64     `json:"rows_per_sec"`
+	Latency          LatencyView `json:"latency"`
+	WALBytes         int64       `json:"wal_bytes"`
+	TableGrowthBytes int64       `json:"table_growth_bytes"`
+	IndexGrowthBytes int64       `json:"index_growth_bytes"`
+}
+
+// WriteBenchmarkView represents write-path metrics for a single strategy
+type WriteBenchmarkView struct {
+	Strategy    string         `json:"strategy"`
+	Computation string         `json:"computation"`
+	Insert      WritePhaseView `json:"insert"`
+	Update      WritePhaseView `json:"update"`
+	Delete      WritePhaseView `json:"delete"`
+}
+
+// WriteBenchmarkResponse represents the write benchmark results
+type WriteBenchmarkResponse struct {
+	RowsPerStrategy int                  `json:"rows_per_strategy"`
+	Results         []WriteBenchmarkView `json:"results"`
+}
+
+func toWritePhaseView(p application.WritePhaseResult) WritePhaseView {
+	return WritePhaseView{
+		Rows:        p.Rows,
+		TotalTimeMs: application.DurationMs(p.Duration),
+		RowsPerSec:  p.RowsPerSec(),
+		Latency: LatencyView{
+			MinMs:  application.DurationMs(p.Latency.Min),
+			MeanMs: application.DurationMs(p.Latency.Mean),
+			P50Ms:  application.DurationMs(p.Latency.P50),
+			P95Ms:  application.DurationMs(p.Latency.P95),
+			P99Ms:  application.DurationMs(p.Latency.P99),
+			MaxMs:  application.DurationMs(p.Latency.Max),
+		},
+		WALBytes:         p.WALBytes,
+		TableGrowthBytes: p.TableGrowthBytes,
+		IndexGrowthBytes: p.IndexGrowthBytes,
+	}
+}
+
+// WriteBenchmark inserts, updates and deletes rows against every writable strategy
+func (r invoicesResource) WriteBenchmark(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		common_http.ErrMethodNotAllowed(w, fmt.Errorf("method %s not allowed, use POST", req.Method))
+		return
+	}
+
+	rows, err := parseBoundedInt(req, "rows", defaultWriteBenchmarkRows, 1, maxWriteBenchmarkRows)
+	if err != nil {
+		common_http.ErrBadRequest(w, err)
+		return
+	}
+
+	results, err := r.service.RunWriteBenchmark(req.Context(), rows)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	response := WriteBenchmarkResponse{
+		RowsPerStrategy: rows,
+		Results:         make([]WriteBenchmarkView, len(results)),
+	}
+	for i, result := range results {
+		response.Results[i] = WriteBenchmarkView{
+			Strategy:    result.Strategy.Name,
+			Computation: string(result.Strategy.Computation),
+			Insert:      toWritePhaseView(result.Insert),
+			Update:      toWritePhaseView(result.Update),
+			Delete:      toWritePhaseView(result.Delete),
+		}
+	}
+
+	writeJSON(w, response)
+}
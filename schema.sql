@@ -19,6 +19,64 @@ CREATE TABLE IF NOT EXISTS invoices_without_virtual (
     tax_rate     NUMERIC(4,2) NOT NULL
 );
 
+-- Table WITH a true virtual generated column (PostgreSQL 18+)
+-- total_cents is computed by the database on every read and never stored
+CREATE TABLE IF NOT EXISTS invoices_with_true_virtual (
+    id           BIGSERIAL PRIMARY KEY,
+    customer_id  BIGINT NOT NULL,
+    amount_cents BIGINT NOT NULL,
+    tax_rate     NUMERIC(4,2) NOT NULL,
+    total_cents  BIGINT GENERATED ALWAYS AS (
+        ROUND(amount_cents * (1 + tax_rate))
+    ) VIRTUAL
+);
+
+-- Table WITH a plain total column maintained by a trigger
+-- total_cents is computed on write by a BEFORE INSERT OR UPDATE trigger
+CREATE TABLE IF NOT EXISTS invoices_with_trigger (
+    id           BIGSERIAL PRIMARY KEY,
+    customer_id  BIGINT NOT NULL,
+    amount_cents BIGINT NOT NULL,
+    tax_rate     NUMERIC(4,2) NOT NULL,
+    total_cents  BIGINT NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION invoices_with_trigger_set_total() RETURNS trigger AS $$
+BEGIN
+    NEW.total_cents := ROUND(NEW.amount_cents * (1 + NEW.tax_rate));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER invoices_with_trigger_set_total
+    BEFORE INSERT OR UPDATE ON invoices_with_trigger
+    FOR EACH ROW EXECUTE FUNCTION invoices_with_trigger_set_total();
+
 -- Indexes for better query performance
 CREATE INDEX IF NOT EXISTS idx_invoices_with_virtual_customer ON invoices_with_virtual(customer_id);
 CREATE INDEX IF NOT EXISTS idx_invoices_without_virtual_customer ON invoices_without_virtual(customer_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_with_true_virtual_customer ON invoices_with_true_virtual(customer_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_with_trigger_customer ON invoices_with_trigger(customer_id);
+
+-- Seeding progress
+-- A run plans the id range it seeds and the settings its rows are derived from,
+-- every batch is recorded in the transaction writing it
+CREATE TABLE IF NOT EXISTS seed_runs (
+    id           BIGSERIAL PRIMARY KEY,
+    first_id     BIGINT NOT NULL,
+    row_count    BIGINT NOT NULL,
+    batch_size   INTEGER NOT NULL,
+    mode         TEXT NOT NULL,
+    random_seed  BIGINT NOT NULL,
+    profile      JSONB NOT NULL,
+    started_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS seed_batches (
+    run_id       BIGINT NOT NULL REFERENCES seed_runs (id),
+    first_id     BIGINT NOT NULL,
+    row_count    INTEGER NOT NULL,
+    committed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (run_id, first_id)
+);